@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/eko/gocache/lib/v4/cache"
+	"github.com/eko/gocache/lib/v4/store"
+)
+
+var ErrAccountRequired = errors.New("several accounts are configured, select one with the 'X-Webook-User' header or the 'user' query parameter")
+var ErrUnknownAccount = errors.New("unknown account")
+var ErrForbiddenAccount = errors.New("account not allowed")
+
+// Account is a WeWork member the server books for. Each account drives its own
+// browser with its own profile, so cookies and tokens are never shared between them
+type Account struct {
+	Name      string
+	Email     string
+	Password  string
+	Locations Locations
+
+	allocCtx  context.Context
+	browser   BrowserConfig
+	weworkURL string
+	// Of the page loads, the same as the WeWork API calls
+	retry        RetryPolicy
+	cancel       context.CancelFunc
+	cacheManager *cache.Cache[[]byte]
+	credentials  *CredentialStore
+	auth0        *Auth0Client
+	// Logging in from two tabs of the same browser at once confuses WeWork
+	sessionMu sync.Mutex
+}
+
+// Tokens are renewed a bit before they expire so they do not expire mid request
+const tokenExpiryMargin = 2 * time.Minute
+
+// Used when the expiry cannot be read from the token
+const defaultTokenLifetime = 10 * time.Minute
+
+// Token returns the cached access token. When it is missing or about to expire, a new
+// one is requested to Auth0 with the stored refresh token, and the browser is only
+// used when there is no usable refresh token
+func (a *Account) Token(ctx context.Context) (string, error) {
+	if token, ok := a.cachedToken(ctx); ok {
+		return token, nil
+	}
+
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+
+	// Another request may have refreshed the token while we were waiting
+	if token, ok := a.cachedToken(ctx); ok {
+		return token, nil
+	}
+
+	token, err := a.newToken(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	expiry, err := tokenExpiry(token)
+
+	if err != nil {
+		log.Println("Could not read the token expiry:", err)
+		expiry = time.Now().Add(defaultTokenLifetime)
+	}
+
+	if lifetime := time.Until(expiry) - tokenExpiryMargin; lifetime > 0 {
+		a.cacheManager.Set(ctx, a.tokenCacheKey(), []byte(token), store.WithExpiration(lifetime))
+	}
+
+	return token, nil
+}
+
+func (a *Account) newToken(ctx context.Context) (string, error) {
+	session, found, err := a.credentials.Load(a.Name)
+
+	if err != nil {
+		log.Println("Could not load the refresh token of", a.Name, ":", err)
+	}
+
+	if found {
+		log.Println("Refreshing the access token of", a.Name)
+
+		refreshed, err := a.auth0.Refresh(ctx, session)
+
+		if err == nil {
+			// With rotation the previous refresh token is no longer valid
+			if err := a.credentials.Save(a.Name, refreshed); err != nil {
+				log.Println("Could not save the refresh token of", a.Name, ":", err)
+			}
+
+			return refreshed.AccessToken, nil
+		}
+
+		log.Println("Could not refresh the access token of", a.Name, ", logging in with the browser:", err)
+
+		if errors.Is(err, ErrRefreshTokenRejected) {
+			if err := a.credentials.Delete(a.Name); err != nil {
+				log.Println("Could not delete the rejected refresh token of", a.Name, ":", err)
+			}
+		}
+	}
+
+	session, err = a.browserSession()
+
+	if err != nil {
+		return "", err
+	}
+
+	return session.AccessToken, nil
+}
+
+// Login goes through the browser, and stores the refresh token so the next access
+// tokens can be fetched without it
+func (a *Account) Login() error {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+
+	session, err := a.browserSession()
+
+	if err != nil {
+		return err
+	}
+
+	if session.RefreshToken == "" {
+		return fmt.Errorf("no refresh token was found for %s", a.Name)
+	}
+
+	return nil
+}
+
+// browserSession logs in with the browser, callers must hold sessionMu
+func (a *Account) browserSession() (Auth0Session, error) {
+	log.Println("Fetching a new access token for", a.Name, "with the browser")
+
+	if a.browser.Remote() {
+		if err := a.browser.waitForRemote(context.Background()); err != nil {
+			return Auth0Session{}, err
+		}
+	}
+
+	taskCtx, cancel, err := openSession(a.allocCtx, a.weworkURL, a.retry, a.Email, a.Password, a.browser.contextOptions()...)
+
+	if err != nil {
+		return Auth0Session{}, err
+	}
+
+	defer cancel()
+
+	// Save cookies
+	defer chromedp.Cancel(taskCtx)
+
+	session, err := getAuth0Session(taskCtx)
+
+	if err != nil {
+		return Auth0Session{}, err
+	}
+
+	if session.RefreshToken == "" {
+		log.Println("WeWork did not issue a refresh token for", a.Name, ", the browser will be needed for every new access token")
+	} else if err := a.credentials.Save(a.Name, session); err != nil {
+		log.Println("Could not save the refresh token of", a.Name, ":", err)
+	}
+
+	return session, nil
+}
+
+func (a *Account) cachedToken(ctx context.Context) (string, bool) {
+	cached, err := a.cacheManager.Get(ctx, a.tokenCacheKey())
+
+	if err != nil || cached == nil {
+		return "", false
+	}
+
+	token := string(cached)
+
+	// The cache expiration should already cover it, but a stale token costs a failed booking
+	if expiry, err := tokenExpiry(token); err == nil && time.Until(expiry) < tokenExpiryMargin {
+		return "", false
+	}
+
+	return token, true
+}
+
+// InvalidateToken forgets the cached token, e.g. when WeWork rejected it
+func (a *Account) InvalidateToken(ctx context.Context) {
+	a.cacheManager.Delete(ctx, a.tokenCacheKey())
+}
+
+// WithToken calls fn with an access token. When WeWork rejects the cached token,
+// fn is called once more with a new one
+func (a *Account) WithToken(ctx context.Context, fn func(bearerToken string) error) error {
+	bearerToken, err := a.Token(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	err = fn(bearerToken)
+
+	if !errors.Is(err, ErrTokenRejected) {
+		return err
+	}
+
+	log.Println("Access token of", a.Name, "was rejected, fetching a new one")
+
+	a.InvalidateToken(ctx)
+
+	bearerToken, err = a.Token(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return fn(bearerToken)
+}
+
+func (a *Account) tokenCacheKey() string {
+	return "wework_token_" + a.Name
+}
+
+type Accounts struct {
+	accounts []*Account
+}
+
+func NewAccounts(config Config, cacheManager *cache.Cache[[]byte], credentials *CredentialStore, auth0 *Auth0Client) *Accounts {
+	accounts := &Accounts{}
+
+	for _, accountConfig := range config.Accounts {
+		allocCtx, cancel := config.Browser.NewAllocator(accountConfig.ProfileDir)
+
+		accounts.accounts = append(accounts.accounts, &Account{
+			Name:     accountConfig.Name,
+			Email:    accountConfig.Email,
+			Password: accountConfig.Password,
+			Locations: Locations{
+				DefaultID: accountConfig.LocationID,
+				Aliases:   config.LocationAliases,
+			},
+			allocCtx:     allocCtx,
+			browser:      config.Browser,
+			weworkURL:    config.WeWorkURL,
+			retry:        config.Retry,
+			cancel:       cancel,
+			cacheManager: cacheManager,
+			credentials:  credentials,
+			auth0:        auth0,
+		})
+	}
+
+	return accounts
+}
+
+func (a *Accounts) All() []*Account {
+	return a.accounts
+}
+
+// Default is the first configured account, used when there is no way to pick one
+func (a *Accounts) Default() *Account {
+	return a.accounts[0]
+}
+
+// Get returns the account by name, an empty name is only accepted when a single account is configured
+func (a *Accounts) Get(name string) (*Account, error) {
+	if name == "" {
+		if len(a.accounts) > 1 {
+			return nil, ErrAccountRequired
+		}
+
+		return a.Default(), nil
+	}
+
+	for _, account := range a.accounts {
+		if account.Name == name {
+			return account, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownAccount, name)
+}
+
+// FromRequest returns the account selected by the request, an API key bound to an
+// account can only act on behalf of that account
+func (a *Accounts) FromRequest(r *http.Request) (*Account, error) {
+	name := r.Header.Get("X-Webook-User")
+
+	if name == "" {
+		name = r.URL.Query().Get("user")
+	}
+
+	if key, ok := apiKeyFromContext(r.Context()); ok && key.Account != "" {
+		if name != "" && name != key.Account {
+			return nil, fmt.Errorf("%w: API key %s can only be used for account %s", ErrForbiddenAccount, key.Name, key.Account)
+		}
+
+		name = key.Account
+	}
+
+	return a.Get(name)
+}
+
+// Close stops the browser of every account
+func (a *Accounts) Close() {
+	for _, account := range a.accounts {
+		account.cancel()
+	}
+}
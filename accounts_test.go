@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/lib/v4/cache"
+	"github.com/eko/gocache/store/go_cache/v4"
+	gocache "github.com/patrickmn/go-cache"
+)
+
+func fakeJWT(expiry time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, expiry.Unix())))
+
+	return "eyJhbGciOiJub25lIn0." + payload + ".signature"
+}
+
+func TestAccountWithCachedToken(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := cache.New[[]byte](go_cache.NewGoCache(gocache.New(time.Hour, time.Minute)))
+
+	// No browser is configured, so the test fails if the account tries to log in
+	account := &Account{Name: "alice", cacheManager: cacheManager}
+
+	token := fakeJWT(time.Now().Add(time.Hour))
+
+	if expiry, err := tokenExpiry(token); err != nil || expiry.Unix() != time.Now().Add(time.Hour).Unix() {
+		t.Fatalf("Unexpected expiry %s %v", expiry, err)
+	}
+
+	cacheManager.Set(ctx, account.tokenCacheKey(), []byte(token))
+
+	calls := 0
+
+	err := account.WithToken(ctx, func(bearerToken string) error {
+		calls++
+
+		if bearerToken != token {
+			t.Errorf("Expected the cached token, but got %s", bearerToken)
+		}
+
+		return nil
+	})
+
+	if err != nil || calls != 1 {
+		t.Errorf("Expected a single call without error, but got %d calls and %v", calls, err)
+	}
+
+	// A token about to expire is not used
+	cacheManager.Set(ctx, account.tokenCacheKey(), []byte(fakeJWT(time.Now().Add(time.Minute))))
+
+	if _, ok := account.cachedToken(ctx); ok {
+		t.Errorf("Did not expect a token expiring within the margin to be used")
+	}
+
+	account.InvalidateToken(ctx)
+
+	if _, ok := account.cachedToken(ctx); ok {
+		t.Errorf("Did not expect a token after invalidation")
+	}
+
+	if _, err := tokenExpiry("not-a-jwt"); err == nil {
+		t.Errorf("Expected error for a token that is not a JWT")
+	}
+
+	// Errors other than a rejected token are returned as is
+	expected := errors.New("boom")
+
+	cacheManager.Set(ctx, account.tokenCacheKey(), []byte(token))
+
+	if err := account.WithToken(ctx, func(string) error { return expected }); err != expected {
+		t.Errorf("Expected %v, but got %v", expected, err)
+	}
+}
@@ -2,92 +2,1038 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/chromedp/chromedp"
 	"github.com/eko/gocache/lib/v4/cache"
 )
 
-func registerBookHandler(allocCtx context.Context, email string, password string, coworkingLocationID string, cacheManager *cache.Cache[[]byte]) func(w http.ResponseWriter, r *http.Request) {
+// openSession creates a new browser tab and makes sure we are logged in to WeWork
+func openSession(allocCtx context.Context, weworkURL string, retry RetryPolicy, email string, password string, opts ...chromedp.ContextOption) (context.Context, context.CancelFunc, error) {
+	taskCtx, cancel := chromedp.NewContext(allocCtx, append([]chromedp.ContextOption{chromedp.WithLogf(log.Printf)}, opts...)...)
+
+	currentPage, err := getPage(taskCtx, weworkURL, retry)
+
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if currentPage == PageLogin {
+		log.Println("Logging in")
+
+		if err := login(taskCtx, weworkURL, email, password); err != nil {
+			log.Println("Login failed:", err)
+			cancel()
+
+			if errors.Is(err, ErrLoginFailed) || errors.Is(err, ErrMFARequired) {
+				return nil, nil, err
+			}
+
+			return nil, nil, fmt.Errorf("%w: %w", ErrLoginFailed, err)
+		}
+
+		log.Println("Navigating to bookings page")
+
+		chromedp.Run(taskCtx,
+			// Wait for page to load, so cookies are set
+			chromedp.Navigate(weworkSupportPageURL(weworkURL)),
+			chromedp.WaitReady(`wework-ondemand-support`, chromedp.ByQuery),
+		)
+	}
+
+	return taskCtx, cancel, nil
+}
+
+// accountFromRequest returns the account selected by the request, or writes the error response
+func accountFromRequest(w http.ResponseWriter, r *http.Request, accounts *Accounts) (*Account, bool) {
+	account, err := accounts.FromRequest(r)
+
+	if err != nil {
+		if errors.Is(err, ErrForbiddenAccount) {
+			writeError(w, r, err, http.StatusForbidden)
+			return nil, false
+		}
+
+		writeError(w, r, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	return account, true
+}
+
+func registerBookHandler(accounts *Accounts, client *WeWorkClient, cacheManager *cache.Cache[[]byte], ledger *Ledger, waitlist *Waitlist) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		bookDatesRequest, err := parseBookDatesRequest(r)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		dates, err := bookDatesRequest.Expand()
+
+		if err != nil {
+			log.Println(err)
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		slot, err := parseTimeSlot(bookDatesRequest.Start, bookDatesRequest.End)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		space, err := newSpaceFilter(bookDatesRequest.SpaceID, bookDatesRequest.Capacity)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		coworkingLocationID, err := account.Locations.Resolve(bookDatesRequest.Location)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Received booking request from", account.Name, "for", dates, slot, space, "at", coworkingLocationID)
+
+		// Several dates get a result per date instead of a single status, the v1 API
+		// answers with JSON for a single date as well
+		if isAPIV1(r) || !bookDatesRequest.IsSingleDate() {
+			var results []BookingResult
+
+			if err := account.WithToken(r.Context(), func(bearerToken string) error {
+				results, err = bookDates(r.Context(), client, bearerToken, account.Name, coworkingLocationID, dates, slot, space, cacheManager, ledger)
+				return err
+			}); err != nil {
+				writeError(w, r, err, http.StatusInternalServerError)
+				return
+			}
+
+			if bookDatesRequest.Waitlist {
+				for i, result := range results {
+					if !errors.Is(result.err, ErrNoSeatsAvailable) {
+						continue
+					}
+
+					if entry, err := waitlist.Add(bookDatesRequest.waitlistEntry(account, coworkingLocationID, result.Date)); err != nil {
+						log.Println("Could not waitlist", result.Date, ":", err)
+					} else {
+						results[i] = result.waitlisted(entry)
+					}
+				}
+			}
+
+			if !isAPIV1(r) {
+				writeJSON(w, http.StatusOK, results)
+				return
+			}
+
+			if bookDatesRequest.IsSingleDate() {
+				writeJSON(w, bookingStatusCode(results[0]), newAPIBooking(results[0]))
+				return
+			}
+
+			bookings := make([]APIBooking, 0, len(results))
+
+			for _, result := range results {
+				bookings = append(bookings, newAPIBooking(result))
+			}
+
+			writeJSON(w, http.StatusOK, bookings)
+			return
+		}
+
+		dateString := dates[0]
+
+		log.Println("Making booking")
+
+		var bookingResponse BookingResponse
+
+		err = account.WithToken(r.Context(), func(bearerToken string) error {
+			bookingResponse, err = makeBooking(r.Context(), client, bearerToken, account.Name, coworkingLocationID, dateString, slot, space, cacheManager, ledger)
+			return err
+		})
+
+		if err != nil {
+			if errors.Is(err, ErrAlreadyBooked) {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprintf(w, "Already booked for date: %s (reservation %s)", dateString, bookingResponse.ReservationID)
+				return
+			}
+
+			if errors.Is(err, ErrNoSeatsAvailable) && bookDatesRequest.Waitlist {
+				entry, waitlistErr := waitlist.Add(bookDatesRequest.waitlistEntry(account, coworkingLocationID, dateString))
+
+				if waitlistErr == nil {
+					w.WriteHeader(http.StatusAccepted)
+					fmt.Fprintf(w, "Waitlisted for date: %s (waitlist %d)", dateString, entry.ID)
+					return
+				}
+
+				log.Println("Could not waitlist", dateString, ":", waitlistErr)
+			}
+
+			// The status tells whether retrying can help, see classifyError
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Booking successful for date:", dateString, "reservation:", bookingResponse.ReservationID)
+
+		// If the date is valid, respond with success
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "Booking successful for date: %s (reservation %s)", dateString, bookingResponse.ReservationID)
+	}
+}
+
+// The longest from/to range that can be booked in a single request
+const maxBookingRangeDays = 62
+
+// BookDatesRequest holds the location and the dates to book, either as a list or as a
+// from/to range optionally filtered on weekdays. It is read from the query or from a JSON body
+type BookDatesRequest struct {
+	Location string   `json:"location"`
+	Dates    []string `json:"dates"`
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Weekdays []string `json:"weekdays"`
+	// Books part of the day, e.g. 08:00 to 12:30, instead of the opening hours
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// A given desk space, or one with enough seats
+	SpaceID  string `json:"spaceId"`
+	Capacity int    `json:"capacity"`
+	// Waits for a seat on the full dates, and books them as soon as one frees up
+	Waitlist bool `json:"waitlist"`
+}
+
+func parseBookDatesRequest(r *http.Request) (BookDatesRequest, error) {
+	query := r.URL.Query()
+
+	bookDatesRequest := BookDatesRequest{
+		Location: query.Get("location"),
+		Dates:    query["date"],
+		From:     query.Get("from"),
+		To:       query.Get("to"),
+		Start:    query.Get("start"),
+		End:      query.Get("end"),
+		SpaceID:  query.Get("spaceId"),
+	}
+
+	// Anything but a boolean is left to the JSON body
+	bookDatesRequest.Waitlist, _ = strconv.ParseBool(query.Get("waitlist"))
+
+	capacity, err := parseCapacity(query.Get("capacity"))
+
+	if err != nil {
+		return BookDatesRequest{}, err
+	}
+
+	bookDatesRequest.Capacity = capacity
+
+	// Weekdays can be repeated or comma separated
+	for _, weekdays := range query["weekdays"] {
+		bookDatesRequest.Weekdays = append(bookDatesRequest.Weekdays, strings.Split(weekdays, ",")...)
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&bookDatesRequest); err != nil {
+			log.Println("Could not decode the booking request:", err)
+			return BookDatesRequest{}, errors.New("Invalid JSON body")
+		}
+	}
+
+	return bookDatesRequest, nil
+}
+
+// waitlistEntry waits for a seat on the date, booked like the request
+func (b BookDatesRequest) waitlistEntry(account *Account, coworkingLocationID string, date string) WaitlistEntry {
+	return WaitlistEntry{
+		Account:    account.Name,
+		LocationID: coworkingLocationID,
+		Date:       date,
+		Start:      b.Start,
+		End:        b.End,
+		SpaceID:    b.SpaceID,
+		Capacity:   b.Capacity,
+	}
+}
+
+// IsSingleDate is true for the original "?date=" request, which keeps its plain text response
+func (b BookDatesRequest) IsSingleDate() bool {
+	return len(b.Dates) == 1 && b.From == "" && b.To == ""
+}
+
+// Expand validates the request and returns the sorted list of unique dates to book
+func (b BookDatesRequest) Expand() ([]string, error) {
+	var dates []time.Time
+
+	for _, date := range b.Dates {
+		d, err := parseDate(date)
+
+		if err != nil {
+			return nil, err
+		}
+
+		dates = append(dates, d)
+	}
+
+	if b.From != "" || b.To != "" {
+		if b.From == "" || b.To == "" {
+			return nil, errors.New("both 'from' and 'to' are required for a range")
+		}
+
+		from, err := parseDate(b.From)
+
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := parseDate(b.To)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if to.Before(from) {
+			return nil, errors.New("'to' must not be before 'from'")
+		}
+
+		if to.Sub(from) > maxBookingRangeDays*24*time.Hour {
+			return nil, fmt.Errorf("range must not be longer than %d days", maxBookingRangeDays)
+		}
+
+		weekdays := map[time.Weekday]bool{}
+
+		for _, day := range b.Weekdays {
+			weekday, err := parseWeekday(day)
+
+			if err != nil {
+				return nil, err
+			}
+
+			weekdays[weekday] = true
+		}
+
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			if len(weekdays) == 0 || weekdays[d.Weekday()] {
+				dates = append(dates, d)
+			}
+		}
+	}
+
+	if len(dates) == 0 {
+		return nil, errors.New("missing 'date' or 'from'/'to' parameters")
+	}
+
+	slices.SortFunc(dates, func(a, b time.Time) int {
+		return a.Compare(b)
+	})
+
+	dates = slices.Compact(dates)
+
+	dateStrings := make([]string, 0, len(dates))
+
+	for _, d := range dates {
+		dateStrings = append(dateStrings, d.Format("Jan 2, 2006"))
+	}
+
+	return dateStrings, nil
+}
+
+var ErrInvalidDate = errors.New("invalid date format")
+
+// parseDate parses a date in the format "Feb 18, 2025"
+func parseDate(date string) (time.Time, error) {
+	d, err := time.Parse("Jan 2, 2006", date)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w %q. Expected format: 'Feb 18, 2025'", ErrInvalidDate, date)
+	}
+
+	return d, nil
+}
+
+func registerCancelHandler(accounts *Accounts, client *WeWorkClient, ledger *Ledger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		date := r.URL.Query().Get("date")
+		reservationID := r.URL.Query().Get("reservationId")
+
+		if date == "" && reservationID == "" {
+			writeError(w, r, errors.New("Missing 'date' or 'reservationId' query parameter"), http.StatusBadRequest)
+			return
+		}
+
+		var dateString string
+
+		if date != "" {
+			var err error
+
+			dateString, err = reformatDate(date)
+
+			if err != nil {
+				log.Println(err)
+				writeError(w, r, fmt.Errorf("%w %q. Expected format: 'Feb 18, 2025'", ErrInvalidDate, date), http.StatusBadRequest)
+				return
+			}
+		}
+
+		coworkingLocationID, err := account.Locations.Resolve(r.URL.Query().Get("location"))
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		log.Println("Received cancel request from", account.Name, "for", dateString, reservationID)
+
+		var booking WeWorkBooking
+		var credits float64
+
+		err = account.WithToken(r.Context(), func(bearerToken string) error {
+			booking, credits, err = cancelBooking(r.Context(), client, bearerToken, account.Name, coworkingLocationID, dateString, reservationID, ledger)
+			return err
+		})
+
+		if err != nil {
+			if errors.Is(err, ErrBookingNotFound) {
+				writeError(w, r, err, http.StatusNotFound)
+				return
+			}
+
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Cancelled reservation", booking.ReservationID)
+
+		if err := ledger.SkipScheduledDate(account, booking.LocationID, booking.Date()); err != nil {
+			log.Println("Could not skip the cancelled date in the schedules:", err)
+		}
+
+		if isAPIV1(r) {
+			writeJSON(w, http.StatusOK, APIBooking{
+				Status:          LedgerStatusCancelled,
+				Date:            booking.Date().Format("Jan 2, 2006"),
+				Location:        booking.LocationID,
+				ReservationID:   booking.ReservationID,
+				CreditsUsed:     booking.CreditsUsed,
+				CreditsRefunded: credits,
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "Cancelled reservation %s for date: %s, %g credits freed", booking.ReservationID, booking.Date().Format("Jan 2, 2006"), credits)
+	}
+}
+
+func registerListBookingsHandler(accounts *Accounts, client *WeWorkClient, cacheManager *cache.Cache[[]byte]) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		var bookings []UpcomingBooking
+
+		err := account.WithToken(r.Context(), func(bearerToken string) error {
+			var err error
+
+			bookings, err = listBookings(r.Context(), client, bearerToken, cacheManager)
+
+			return err
+		})
+
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(bookings)
+	}
+}
+
+func registerSearchLocationsHandler(accounts *Accounts, client *WeWorkClient) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query().Get("query")
+
+		if strings.TrimSpace(query) == "" {
+			writeError(w, r, errors.New("Missing 'query' query parameter"), http.StatusBadRequest)
+			return
+		}
+
+		var results []LocationSearchResult
+
+		err := account.WithToken(r.Context(), func(bearerToken string) error {
+			var err error
+
+			results, err = searchLocations(r.Context(), client, bearerToken, query)
+
+			return err
+		})
+
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
+func registerListSpacesHandler(accounts *Accounts, client *WeWorkClient) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query()
+
+		capacity, err := parseCapacity(query.Get("capacity"))
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		filter, err := newSpaceFilter("", capacity)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		coworkingLocationID, err := account.Locations.Resolve(query.Get("location"))
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		var spaces []SpaceResult
+
+		err = account.WithToken(r.Context(), func(bearerToken string) error {
+			var err error
+
+			spaces, err = listSpaces(r.Context(), client, bearerToken, coworkingLocationID, filter)
+
+			return err
+		})
+
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(spaces)
+	}
+}
+
+func registerAvailabilityHandler(accounts *Accounts, client *WeWorkClient) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
 			return
 		}
 
-		// Extract the "date" query parameter
-		date := r.URL.Query().Get("date")
+		availabilityRequest, err := parseBookDatesRequest(r)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
 
-		if date == "" {
-			http.Error(w, "Missing 'date' query parameter", http.StatusBadRequest)
+		dates, err := availabilityRequest.Expand()
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
 			return
 		}
 
-		log.Println("Received booking request for", date)
+		space, err := newSpaceFilter(availabilityRequest.SpaceID, availabilityRequest.Capacity)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
 
-		// Validate the date format (e.g., "Feb 18, 2025")
-		dateString, err := reformatDate(date)
+		coworkingLocationID, err := account.Locations.Resolve(availabilityRequest.Location)
 
 		if err != nil {
-			log.Println(err)
-			http.Error(w, "Invalid date format. Expected format: 'Feb 18, 2025'", http.StatusBadRequest)
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		availabilities := make([]Availability, 0, len(dates))
+
+		err = account.WithToken(r.Context(), func(bearerToken string) error {
+			availabilities = availabilities[:0]
+			now := time.Now()
+
+			for _, date := range dates {
+				// Validated by Expand
+				d, _ := parseDate(date)
+
+				availability, err := checkAvailability(r.Context(), client, bearerToken, coworkingLocationID, d, space, now)
+
+				if err != nil {
+					return err
+				}
+
+				availabilities = append(availabilities, availability)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(availabilities)
+	}
+}
+
+func registerListWaitlistHandler(accounts *Accounts, ledger *Ledger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		entries, err := ledger.WaitlistEntries()
+
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
 			return
 		}
 
-		taskCtx, cancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
-		defer cancel()
+		entries = slices.DeleteFunc(entries, func(entry WaitlistEntry) bool {
+			return !entry.BelongsTo(account)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+// registerDeleteWaitlistHandler stops waiting for a seat, a date already booked has to be cancelled
+func registerDeleteWaitlistHandler(accounts *Accounts, ledger *Ledger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
 
-		// Save cookies
-		defer chromedp.Cancel(taskCtx)
+		if !ok {
+			return
+		}
 
-		currentPage, err := getPage(taskCtx)
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
 
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, errors.New("Invalid waitlist ID"), http.StatusBadRequest)
 			return
 		}
 
-		if currentPage == PageLogin {
-			log.Println("Logging in")
+		entry, err := ledger.WaitlistEntry(id)
+
+		if err == nil && !entry.BelongsTo(account) {
+			err = ErrWaitlistEntryNotFound
+		}
+
+		if err == nil {
+			err = ledger.DeleteWaitlistEntry(id)
+		}
 
-			if err := login(taskCtx, email, password); err != nil {
-				log.Println("Login failed:", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err != nil {
+			if errors.Is(err, ErrWaitlistEntryNotFound) {
+				writeError(w, r, err, http.StatusNotFound)
 				return
 			}
 
-			log.Println("Navigating to bookings page")
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Deleted waitlist entry", id)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func registerLedgerHandler(accounts *Accounts, ledger *Ledger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
 
-			chromedp.Run(taskCtx,
-				// Wait for page to load, so cookies are set
-				chromedp.Navigate(`https://members.wework.com/workplaceone/content2/wework-support`),
-				chromedp.WaitReady(`wework-ondemand-support`, chromedp.ByQuery),
-			)
+		if !ok {
+			return
 		}
 
-		log.Println("Making booking")
+		entries, err := ledger.Entries(account.Name)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+// registerCreditsHandler tells what the bot spent in the month, what is left of the budget
+// and, when a date is given, what booking it would cost
+func registerCreditsHandler(accounts *Accounts, client *WeWorkClient, cacheManager *cache.Cache[[]byte], ledger *Ledger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query()
+		month := time.Now()
+
+		if value := query.Get("month"); value != "" {
+			var err error
+
+			month, err = time.Parse("2006-01", value)
 
-		if err := makeBooking(taskCtx, coworkingLocationID, dateString, cacheManager); err != nil {
-			if errors.Is(err, ErrDateInOlderThanOneMonthFuture) {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+			if err != nil {
+				writeError(w, r, fmt.Errorf("%w %q. Expected format: '2025-02'", ErrInvalidDate, value), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var date time.Time
+		var slot TimeSlot
+		var space SpaceFilter
+		var coworkingLocationID string
+
+		if value := query.Get("date"); value != "" {
+			var err error
+
+			if date, err = parseDate(value); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+
+			if slot, err = parseTimeSlot(query.Get("start"), query.Get("end")); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+
+			capacity, err := parseCapacity(query.Get("capacity"))
+
+			if err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+
+			if space, err = newSpaceFilter(query.Get("spaceId"), capacity); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
 				return
 			}
 
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			if coworkingLocationID, err = account.Locations.Resolve(query.Get("location")); err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+
+			// The budget of the month of the booking
+			month = date
+		}
+
+		spent, err := ledger.CreditsSpent(account.Name, month)
+
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
 			return
 		}
 
-		log.Println("Booking successful for date:", dateString)
+		report := CreditsReport{
+			Account: account.Name,
+			Month:   month.Format("2006-01"),
+			Spent:   spent,
+			Budget:  client.budget.Monthly,
+		}
+
+		if report.Budget > 0 {
+			remaining := max(report.Budget-spent, 0)
+			report.Remaining = &remaining
+		}
 
-		// If the date is valid, respond with success
-		w.WriteHeader(http.StatusOK)
+		if !date.IsZero() {
+			err = account.WithToken(r.Context(), func(bearerToken string) error {
+				cost, err := bookingCost(r.Context(), client, bearerToken, cacheManager, coworkingLocationID, date, slot, space)
+
+				if err != nil {
+					return err
+				}
+
+				report.Cost = &cost
+
+				return nil
+			})
+
+			if err != nil {
+				writeError(w, r, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+func registerListSchedulesHandler(accounts *Accounts, ledger *Ledger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		schedules, err := ledger.Schedules()
+
+		if err != nil {
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		schedules = slices.DeleteFunc(schedules, func(schedule Schedule) bool {
+			return !schedule.BelongsTo(account)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(schedules)
+	}
+}
+
+func registerGetScheduleHandler(accounts *Accounts, ledger *Ledger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+
+		if err != nil {
+			writeError(w, r, errors.New("Invalid schedule ID"), http.StatusBadRequest)
+			return
+		}
+
+		schedule, err := getAccountSchedule(ledger, account, id)
+
+		if err != nil {
+			if errors.Is(err, ErrScheduleNotFound) {
+				writeError(w, r, err, http.StatusNotFound)
+				return
+			}
+
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(schedule)
+	}
+}
+
+// registerSaveScheduleHandler creates a schedule, or replaces it when called with an ID in the path
+func registerSaveScheduleHandler(accounts *Accounts, ledger *Ledger, scheduler *Scheduler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		var schedule Schedule
+
+		if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+			writeError(w, r, errors.New("Invalid JSON body"), http.StatusBadRequest)
+			return
+		}
+
+		schedule.ID = 0
+
+		if r.PathValue("id") != "" {
+			id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+
+			if err != nil {
+				writeError(w, r, errors.New("Invalid schedule ID"), http.StatusBadRequest)
+				return
+			}
+
+			if _, err := getAccountSchedule(ledger, account, id); err != nil {
+				if errors.Is(err, ErrScheduleNotFound) {
+					writeError(w, r, err, http.StatusNotFound)
+					return
+				}
+
+				writeError(w, r, err, http.StatusInternalServerError)
+				return
+			}
+
+			schedule.ID = id
+		}
 
-		fmt.Fprintf(w, "Booking successful for date: %s", dateString)
+		schedule.Account = account.Name
+
+		if err := schedule.Normalize(); err != nil {
+			writeError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		// An empty location is kept as is so the schedule follows the default location
+		if schedule.LocationID != "" {
+			locationID, err := account.Locations.Resolve(schedule.LocationID)
+
+			if err != nil {
+				writeError(w, r, err, http.StatusBadRequest)
+				return
+			}
+
+			schedule.LocationID = locationID
+		}
+
+		schedule, err := ledger.SaveSchedule(schedule)
+
+		if err != nil {
+			if errors.Is(err, ErrScheduleNotFound) {
+				writeError(w, r, err, http.StatusNotFound)
+				return
+			}
+
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Saved schedule", schedule.ID, schedule.Weekdays)
+
+		// Book the dates already within the booking window right away
+		scheduler.Trigger()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.PathValue("id") == "" {
+			w.WriteHeader(http.StatusCreated)
+		}
+
+		json.NewEncoder(w).Encode(schedule)
+	}
+}
+
+func registerDeleteScheduleHandler(accounts *Accounts, ledger *Ledger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := accountFromRequest(w, r, accounts)
+
+		if !ok {
+			return
+		}
+
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+
+		if err != nil {
+			writeError(w, r, errors.New("Invalid schedule ID"), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getAccountSchedule(ledger, account, id); err != nil {
+			if errors.Is(err, ErrScheduleNotFound) {
+				writeError(w, r, err, http.StatusNotFound)
+				return
+			}
+
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		if err := ledger.DeleteSchedule(id); err != nil {
+			if errors.Is(err, ErrScheduleNotFound) {
+				writeError(w, r, err, http.StatusNotFound)
+				return
+			}
+
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		log.Println("Deleted schedule", id)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getAccountSchedule hides the schedules of the other accounts behind ErrScheduleNotFound
+func getAccountSchedule(ledger *Ledger, account *Account, id uint64) (Schedule, error) {
+	schedule, err := ledger.Schedule(id)
+
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	if !schedule.BelongsTo(account) {
+		return Schedule{}, ErrScheduleNotFound
 	}
+
+	return schedule, nil
 }
 
 // reformatDate validates the date string against the format "Feb 18, 2025"
@@ -102,3 +1048,16 @@ func reformatDate(date string) (string, error) {
 	// Reformating the date so we don't have Mar 03, 2025 which does not work
 	return d.Format("Jan 2, 2006"), nil
 }
+
+// registerHealthHandler answers 200 when the remote Chrome used to log in answers, so
+// orchestrators can wait for it. It does not log in, WeWork being down is not checked
+func registerHealthHandler(browser BrowserConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := browser.CheckHealth(r.Context()); err != nil {
+			http.Error(w, "remote Chrome is not reachable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	}
+}
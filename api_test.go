@@ -1,6 +1,13 @@
 package main
 
-import "testing"
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
 
 func TestReformatDate(t *testing.T) {
 	tests := []struct {
@@ -31,3 +38,56 @@ func TestReformatDate(t *testing.T) {
 		}
 	}
 }
+
+func TestBookDatesRequestExpand(t *testing.T) {
+	tests := []struct {
+		request  BookDatesRequest
+		expected []string
+		hasError bool
+	}{
+		{BookDatesRequest{Dates: []string{"Feb 19, 2025", "Feb 18, 2025", "Feb 19, 2025"}}, []string{"Feb 18, 2025", "Feb 19, 2025"}, false},
+		{BookDatesRequest{From: "Feb 17, 2025", To: "Feb 23, 2025", Weekdays: []string{"tue", "thu"}}, []string{"Feb 18, 2025", "Feb 20, 2025"}, false},
+		{BookDatesRequest{From: "Feb 17, 2025", To: "Feb 19, 2025"}, []string{"Feb 17, 2025", "Feb 18, 2025", "Feb 19, 2025"}, false},
+		{BookDatesRequest{Dates: []string{"Feb 21, 2025"}, From: "Feb 17, 2025", To: "Feb 17, 2025"}, []string{"Feb 17, 2025", "Feb 21, 2025"}, false},
+		{BookDatesRequest{From: "Feb 17, 2025"}, nil, true},
+		{BookDatesRequest{From: "Feb 19, 2025", To: "Feb 17, 2025"}, nil, true},
+		{BookDatesRequest{From: "Jan 1, 2025", To: "Dec 31, 2025"}, nil, true},
+		{BookDatesRequest{From: "Feb 17, 2025", To: "Feb 19, 2025", Weekdays: []string{"funday"}}, nil, true},
+		{BookDatesRequest{Dates: []string{"2025-02-18"}}, nil, true},
+		{BookDatesRequest{}, nil, true},
+	}
+
+	for _, test := range tests {
+		result, err := test.request.Expand()
+
+		if test.hasError {
+			if err == nil {
+				t.Errorf("Expected error for request %+v, but got none", test.request)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Did not expect error for request %+v, but got %v", test.request, err)
+		}
+
+		if !slices.Equal(result, test.expected) {
+			t.Errorf("For request %+v, expected %v, but got %v", test.request, test.expected, result)
+		}
+	}
+}
+
+func TestParseBookDatesRequestErrors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/book?capacity=six", nil)
+
+	if _, err := parseBookDatesRequest(r); !errors.Is(err, ErrInvalidSpace) {
+		t.Errorf("Expected the capacity to be rejected, but got %v", err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/api/v1/book", strings.NewReader(`{"dates": [`))
+	r.Header.Set("Content-Type", "application/json")
+
+	if _, err := parseBookDatesRequest(r); err == nil || err.Error() != "Invalid JSON body" {
+		t.Errorf("Expected an invalid JSON body, but got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ScopeBook = "book"
+const ScopeCancel = "cancel"
+const ScopeRead = "read"
+
+var AllScopes = []string{ScopeBook, ScopeCancel, ScopeRead}
+
+// How far the timestamp of a signed request can be from now, to limit replays
+const maxSignatureAge = 5 * time.Minute
+
+// Signed request bodies are read in memory to be verified
+const maxSignedBodySize = 1 << 20
+
+var ErrUnauthorized = errors.New("missing or invalid credentials")
+
+// APIKeyConfig is a client allowed to call the API, either by sending the key as a
+// bearer token, or by signing its requests with the secret (e.g. webhooks)
+type APIKeyConfig struct {
+	Name    string   `yaml:"name"`
+	Key     string   `yaml:"key"`
+	Secret  string   `yaml:"secret"`
+	Account string   `yaml:"account"`
+	Scopes  []string `yaml:"scopes"`
+}
+
+type apiKeyContextKey struct{}
+
+type Authenticator struct {
+	keys []APIKeyConfig
+	now  func() time.Time
+}
+
+func NewAuthenticator(keys []APIKeyConfig) *Authenticator {
+	return &Authenticator{keys: keys, now: time.Now}
+}
+
+// Enabled is false when no key is configured, in which case the API is open
+func (a *Authenticator) Enabled() bool {
+	return len(a.keys) > 0
+}
+
+// Require wraps the handler so it is only called for requests authenticated with a key having the scope
+func (a *Authenticator) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next(w, r)
+			return
+		}
+
+		key, err := a.authenticate(r)
+
+		if err != nil {
+			log.Println("Rejected request to", r.URL.Path, ":", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="webook"`)
+			writeError(w, r, ErrUnauthorized, http.StatusUnauthorized)
+			return
+		}
+
+		if !slices.Contains(key.Scopes, scope) {
+			writeError(w, r, fmt.Errorf("API key %s does not have the %s scope", key.Name, scope), http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	}
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (*APIKeyConfig, error) {
+	if signature := r.Header.Get("X-Webook-Signature"); signature != "" {
+		return a.authenticateSignature(r, signature)
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if !found || token == "" {
+		return nil, errors.New("missing bearer token")
+	}
+
+	for i := range a.keys {
+		key := &a.keys[i]
+
+		if key.Key != "" && subtle.ConstantTimeCompare([]byte(key.Key), []byte(token)) == 1 {
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("unknown API key")
+}
+
+// authenticateSignature checks the X-Webook-Signature header, which is the hex encoded
+// HMAC-SHA256 with the key secret of "<timestamp>\n<method>\n<request URI>\n<body>".
+// The key is named by X-Webook-Key and the unix timestamp is sent in X-Webook-Timestamp
+func (a *Authenticator) authenticateSignature(r *http.Request, signature string) (*APIKeyConfig, error) {
+	name := r.Header.Get("X-Webook-Key")
+
+	var key *APIKeyConfig
+
+	for i := range a.keys {
+		if a.keys[i].Name == name && a.keys[i].Secret != "" {
+			key = &a.keys[i]
+		}
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", name)
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get("X-Webook-Timestamp"), 10, 64)
+
+	if err != nil {
+		return nil, errors.New("invalid signature timestamp")
+	}
+
+	if age := a.now().Sub(time.Unix(timestamp, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return nil, errors.New("signature timestamp is too old")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The handler still needs to read the body
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	expected := signRequest(key.Secret, timestamp, r.Method, r.URL.RequestURI(), body)
+
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimPrefix(signature, "sha256="))) {
+		return nil, errors.New("invalid signature")
+	}
+
+	return key, nil
+}
+
+func signRequest(secret string, timestamp int64, method string, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+
+	fmt.Fprintf(mac, "%d\n%s\n%s\n", timestamp, method, requestURI)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// apiKeyFromContext returns the key the request was authenticated with
+func apiKeyFromContext(ctx context.Context) (*APIKeyConfig, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(*APIKeyConfig)
+
+	return key, ok
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"resty.dev/v3"
+)
+
+// Auth0Session is what is needed to renew access tokens without the browser
+type Auth0Session struct {
+	Domain       string `json:"domain"`
+	ClientID     string `json:"clientId"`
+	AccessToken  string `json:"-"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// getAuth0Session reads the Auth0 SPA cache from the localStorage of the logged in page
+func getAuth0Session(ctx context.Context) (Auth0Session, error) {
+	var result struct {
+		Domain       string `json:"domain"`
+		ClientID     string `json:"clientId"`
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	if err := chromedp.Run(ctx,
+		chromedp.EvaluateAsDevTools(`(function() {
+			const baseItems = localStorage.getItem('Auth0Config');
+
+			if (!baseItems) {
+				throw new Error("could not find Auth0Config in local storage");
+			}
+			const config = JSON.parse(baseItems);
+
+			const { clientId, domain, authorizationParams: { scope } } = config;
+
+			const items = localStorage.getItem('@@auth0spajs@@::' + clientId + '::wework::openid ' + scope);
+
+			if (!items) {
+				throw new Error("could not find auth0 items in local storage");
+			}
+
+			const { body } = JSON.parse(items);
+
+			return {
+				domain: domain,
+				clientId: clientId,
+				accessToken: body.access_token,
+				refreshToken: body.refresh_token || "",
+			};
+		})()`, &result),
+	); err != nil {
+		return Auth0Session{}, fmt.Errorf("%w: %w", ErrTokenNotFound, err)
+	}
+
+	if result.AccessToken == "" {
+		return Auth0Session{}, ErrTokenNotFound
+	}
+
+	return Auth0Session{
+		Domain:       result.Domain,
+		ClientID:     result.ClientID,
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+	}, nil
+}
+
+type auth0TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type auth0ErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Auth0 answers in well under a second, the account lock is held while it refreshes
+const auth0RequestTimeout = 15 * time.Second
+
+// Auth0Client renews the access tokens of every account with a single resty client
+type Auth0Client struct {
+	client *resty.Client
+}
+
+func NewAuth0Client() *Auth0Client {
+	client := resty.New().
+		SetTimeout(auth0RequestTimeout).
+		SetHeader("User-Agent", weworkUserAgent)
+
+	return &Auth0Client{client: client}
+}
+
+func (c *Auth0Client) Close() error {
+	return c.client.Close()
+}
+
+// Refresh gets a new access token from Auth0. With refresh token rotation Auth0 also
+// returns a new refresh token, which replaces the one that was used
+func (c *Auth0Client) Refresh(ctx context.Context, session Auth0Session) (Auth0Session, error) {
+	var tokenResponse auth0TokenResponse
+	var errorResponse auth0ErrorResponse
+
+	response, err := c.client.R().
+		SetContext(ctx).
+		SetBody(map[string]string{
+			"grant_type":    "refresh_token",
+			"client_id":     session.ClientID,
+			"refresh_token": session.RefreshToken,
+		}).
+		SetResult(&tokenResponse).
+		SetError(&errorResponse).
+		Post(fmt.Sprintf("https://%s/oauth/token", session.Domain))
+
+	if err != nil {
+		return Auth0Session{}, err
+	}
+
+	if response.IsError() {
+		if errorResponse.Error == "invalid_grant" {
+			return Auth0Session{}, fmt.Errorf("%w: %s", ErrRefreshTokenRejected, errorResponse.ErrorDescription)
+		}
+
+		return Auth0Session{}, fmt.Errorf("error refreshing token: %s %s", response.Status(), errorResponse.ErrorDescription)
+	}
+
+	if tokenResponse.AccessToken == "" {
+		return Auth0Session{}, errors.New("Auth0 did not return an access token")
+	}
+
+	session.AccessToken = tokenResponse.AccessToken
+
+	if tokenResponse.RefreshToken != "" {
+		session.RefreshToken = tokenResponse.RefreshToken
+	}
+
+	return session, nil
+}
+
+// CredentialStore keeps the Auth0 refresh tokens. They are encrypted in the ledger when
+// a secret key is configured, and only kept in memory otherwise
+type CredentialStore struct {
+	ledger *Ledger
+	aead   cipher.AEAD
+
+	mu     sync.Mutex
+	memory map[string]Auth0Session
+}
+
+func NewCredentialStore(ledger *Ledger, secretKey string) (*CredentialStore, error) {
+	store := &CredentialStore{ledger: ledger, memory: map[string]Auth0Session{}}
+
+	if secretKey == "" {
+		return store, nil
+	}
+
+	key := sha256.Sum256([]byte(secretKey))
+
+	block, err := aes.NewCipher(key[:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	store.aead, err = cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Persistent is false when the refresh tokens are lost on restart
+func (c *CredentialStore) Persistent() bool {
+	return c.aead != nil
+}
+
+func (c *CredentialStore) Load(account string) (Auth0Session, bool, error) {
+	if !c.Persistent() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		session, found := c.memory[account]
+
+		return session, found, nil
+	}
+
+	sealed, err := c.ledger.Credentials(account)
+
+	if err != nil || sealed == nil {
+		return Auth0Session{}, false, err
+	}
+
+	nonceSize := c.aead.NonceSize()
+
+	if len(sealed) < nonceSize {
+		return Auth0Session{}, false, errors.New("stored credentials are corrupted")
+	}
+
+	data, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(account))
+
+	if err != nil {
+		return Auth0Session{}, false, errors.New("could not decrypt stored credentials, was the secret key changed?")
+	}
+
+	var session Auth0Session
+
+	if err := json.Unmarshal(data, &session); err != nil {
+		return Auth0Session{}, false, err
+	}
+
+	return session, true, nil
+}
+
+func (c *CredentialStore) Save(account string, session Auth0Session) error {
+	if !c.Persistent() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.memory[account] = session
+
+		return nil
+	}
+
+	data, err := json.Marshal(session)
+
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	// The account name is authenticated so credentials cannot be swapped between accounts
+	return c.ledger.SaveCredentials(account, c.aead.Seal(nonce, nonce, data, []byte(account)))
+}
+
+func (c *CredentialStore) Delete(account string) error {
+	if !c.Persistent() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		delete(c.memory, account)
+
+		return nil
+	}
+
+	return c.ledger.DeleteCredentials(account)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentialStore(t *testing.T) {
+	ledger, err := OpenLedger(filepath.Join(t.TempDir(), "webook.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ledger.Close()
+
+	credentials, err := NewCredentialStore(ledger, "secret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := Auth0Session{Domain: "idp.wework.com", ClientID: "client", AccessToken: "access", RefreshToken: "refresh"}
+
+	if err := credentials.Save("alice", session); err != nil {
+		t.Fatal(err)
+	}
+
+	// The refresh token must not be stored in clear
+	if sealed, err := ledger.Credentials("alice"); err != nil || len(sealed) == 0 || strings.Contains(string(sealed), "refresh") {
+		t.Fatalf("Unexpected stored credentials %q %v", sealed, err)
+	}
+
+	loaded, found, err := credentials.Load("alice")
+
+	if err != nil || !found {
+		t.Fatalf("Expected stored credentials, but got %v %v", found, err)
+	}
+
+	// The access token is short lived and is not stored
+	if loaded.RefreshToken != "refresh" || loaded.ClientID != "client" || loaded.Domain != "idp.wework.com" || loaded.AccessToken != "" {
+		t.Errorf("Unexpected credentials %+v", loaded)
+	}
+
+	if _, found, err := credentials.Load("bob"); found || err != nil {
+		t.Errorf("Did not expect credentials for bob, but got %v %v", found, err)
+	}
+
+	otherKey, err := NewCredentialStore(ledger, "other secret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := otherKey.Load("alice"); err == nil {
+		t.Errorf("Expected error when decrypting with another key")
+	}
+
+	if err := credentials.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found, _ := credentials.Load("alice"); found {
+		t.Errorf("Did not expect credentials after deletion")
+	}
+
+	// Without a secret key nothing is written to the ledger
+	memory, err := NewCredentialStore(ledger, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := memory.Save("alice", session); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded, found, _ := memory.Load("alice"); !found || loaded.RefreshToken != "refresh" {
+		t.Errorf("Expected credentials kept in memory, but got %+v", loaded)
+	}
+
+	if sealed, _ := ledger.Credentials("alice"); sealed != nil {
+		t.Errorf("Did not expect credentials in the ledger without a secret key")
+	}
+}
+
+func TestAuth0ClientRefresh(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if r.URL.Path != "/oauth/token" || body["grant_type"] != "refresh_token" || body["client_id"] != "client" {
+			t.Errorf("Unexpected refresh request %s %v", r.URL.Path, body)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if body["refresh_token"] != "refresh-1" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"Unknown or invalid refresh token."}`))
+			return
+		}
+
+		w.Write([]byte(`{"access_token":"access-2","refresh_token":"refresh-2","expires_in":600}`))
+	}))
+	t.Cleanup(server.Close)
+
+	auth0 := NewAuth0Client()
+	auth0.client.SetTransport(server.Client().Transport)
+	t.Cleanup(func() { auth0.Close() })
+
+	session := Auth0Session{Domain: strings.TrimPrefix(server.URL, "https://"), ClientID: "client", RefreshToken: "refresh-1"}
+
+	refreshed, err := auth0.Refresh(context.Background(), session)
+
+	if err != nil || refreshed.AccessToken != "access-2" || refreshed.RefreshToken != "refresh-2" {
+		t.Fatalf("Expected the rotated session, but got %+v and %v", refreshed, err)
+	}
+
+	if _, err := auth0.Refresh(context.Background(), refreshed); !errors.Is(err, ErrRefreshTokenRejected) {
+		t.Errorf("Expected ErrRefreshTokenRejected, but got %v", err)
+	}
+}
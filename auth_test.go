@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticatorRequire(t *testing.T) {
+	now := time.Date(2025, 2, 17, 10, 0, 0, 0, time.UTC)
+
+	auth := NewAuthenticator([]APIKeyConfig{
+		{Name: "reader", Key: "read-key", Scopes: []string{ScopeRead}},
+		{Name: "booker", Key: "book-key", Scopes: []string{ScopeBook, ScopeRead}},
+		{Name: "webhook", Secret: "webhook-secret", Scopes: []string{ScopeBook}},
+	})
+	auth.now = func() time.Time { return now }
+
+	var receivedBody string
+
+	handler := auth.Require(ScopeBook, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	signed := func(secret string, timestamp time.Time, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/book?date=Feb%2018,%202025", strings.NewReader(body))
+		r.Header.Set("X-Webook-Key", "webhook")
+		r.Header.Set("X-Webook-Timestamp", strconv.FormatInt(timestamp.Unix(), 10))
+		r.Header.Set("X-Webook-Signature", "sha256="+signRequest(secret, timestamp.Unix(), http.MethodPost, "/api/book?date=Feb%2018,%202025", []byte(body)))
+		return r
+	}
+
+	bearer := func(key string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/book", nil)
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		request  *http.Request
+		expected int
+	}{
+		{"no credentials", bearer(""), http.StatusUnauthorized},
+		{"unknown key", bearer("nope"), http.StatusUnauthorized},
+		{"missing scope", bearer("read-key"), http.StatusForbidden},
+		{"valid key", bearer("book-key"), http.StatusOK},
+		{"valid signature", signed("webhook-secret", now, `{"dates":["Feb 18, 2025"]}`), http.StatusOK},
+		{"wrong secret", signed("other-secret", now, "{}"), http.StatusUnauthorized},
+		{"expired signature", signed("webhook-secret", now.Add(-10*time.Minute), "{}"), http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+
+		handler(recorder, test.request)
+
+		if recorder.Code != test.expected {
+			t.Errorf("For %s, expected status %d, but got %d", test.name, test.expected, recorder.Code)
+		}
+	}
+
+	// The body read to check the signature is still available to the handler
+	recorder := httptest.NewRecorder()
+	handler(recorder, signed("webhook-secret", now, `{"dates":["Feb 18, 2025"]}`))
+
+	if receivedBody != `{"dates":["Feb 18, 2025"]}` {
+		t.Errorf("Expected the handler to receive the body, but got %q", receivedBody)
+	}
+}
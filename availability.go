@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AvailableSeats is what is left of the space, from whichever count WeWork filled, 0 when
+// the space is full. False when WeWork tells none of them, the space is then assumed to be
+// available
+func (s WeWorkLocation) AvailableSeats() (int, bool) {
+	switch {
+	case s.Seat.Total > 0:
+		return s.Seat.Available, true
+	case s.SeatsAvailable != nil:
+		return *s.SeatsAvailable, true
+	case s.Location.SpaceAvailabilityCount != nil:
+		return *s.Location.SpaceAvailabilityCount, true
+	default:
+		return 0, false
+	}
+}
+
+// Availability of the spaces matching the filter for a date
+type Availability struct {
+	Date      string `json:"date"`
+	Available bool   `json:"available"`
+	// Missing when WeWork does not tell
+	SeatsAvailable *int `json:"seatsAvailable,omitempty"`
+}
+
+// seatsKnown is true when the date may be today somewhere. WeWork only tells the seats
+// left today, so for any other date the space is assumed to be available
+func seatsKnown(date time.Time, now time.Time) bool {
+	year, month, day := now.UTC().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	return !date.Before(today.AddDate(0, 0, -1)) && !date.After(today.AddDate(0, 0, 1))
+}
+
+// isTodayAt is true when date is the current day in the timezone of the space
+func isTodayAt(space WeWorkLocation, date time.Time, now time.Time) bool {
+	timezone, err := spaceTimezone(space)
+
+	if err != nil {
+		return false
+	}
+
+	year, month, day := now.In(timezone).Date()
+
+	return date.Year() == year && date.Month() == month && date.Day() == day
+}
+
+// checkAvailability asks WeWork what is left on that date of the spaces matching the
+// filter. WeWork is not asked for the dates it cannot tell, see seatsKnown
+func checkAvailability(ctx context.Context, client *WeWorkClient, bearerToken string, coworkingLocationID string, date time.Time, space SpaceFilter, now time.Time) (Availability, error) {
+	availability := Availability{Date: date.Format("Jan 2, 2006")}
+
+	if !seatsKnown(date, now) {
+		availability.Available = true
+		return availability, nil
+	}
+
+	spaces, err := getWeWorkSpaces(ctx, client, bearerToken, coworkingLocationID)
+
+	if err != nil {
+		return Availability{}, err
+	}
+
+	found := false
+
+	for _, weworkSpace := range spaces {
+		if !space.Matches(weworkSpace) {
+			continue
+		}
+
+		found = true
+		seats, known := weworkSpace.AvailableSeats()
+
+		if !known || !isTodayAt(weworkSpace, date, now) {
+			availability.Available = true
+			continue
+		}
+
+		if availability.SeatsAvailable == nil {
+			availability.SeatsAvailable = new(int)
+		}
+
+		*availability.SeatsAvailable += seats
+		availability.Available = availability.Available || seats > 0
+	}
+
+	if !found {
+		return Availability{}, fmt.Errorf("%w: no %s at %s", ErrSpaceNotFound, space, coworkingLocationID)
+	}
+
+	return availability, nil
+}
+
+// ensureAvailable fails with ErrNoSeatsAvailable when the space is full on that date. The
+// booking goes on when WeWork cannot tell, it rejects it anyway if the space is full
+func ensureAvailable(ctx context.Context, client *WeWorkClient, bearerToken string, coworkingLocationID string, date time.Time, space SpaceFilter, now time.Time) error {
+	availability, err := checkAvailability(ctx, client, bearerToken, coworkingLocationID, date, space, now)
+
+	if err != nil {
+		return err
+	}
+
+	if !availability.Available {
+		return fmt.Errorf("%w: %s is full on %s", ErrNoSeatsAvailable, space, availability.Date)
+	}
+
+	return nil
+}
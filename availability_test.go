@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAvailableSeats(t *testing.T) {
+	tests := []struct {
+		response string
+		seats    int
+		known    bool
+	}{
+		{`{"seat": {"total": 10, "available": 0}}`, 0, true},
+		{`{"seat": {"total": 10, "available": 3}, "seatsAvailable": 5}`, 3, true},
+		{`{"seatsAvailable": 0}`, 0, true},
+		{`{"seatsAvailable": 2}`, 2, true},
+		{`{"location": {"spaceAvailabilityCount": 0}}`, 0, true},
+		{`{"location": {"spaceAvailabilityCount": 4}}`, 4, true},
+		{`{}`, 0, false},
+	}
+
+	for _, test := range tests {
+		var space WeWorkLocation
+
+		if err := json.Unmarshal([]byte(test.response), &space); err != nil {
+			t.Fatal(err)
+		}
+
+		if seats, known := space.AvailableSeats(); seats != test.seats || known != test.known {
+			t.Errorf("For %s, expected %d seats and known %v, but got %d and %v", test.response, test.seats, test.known, seats, known)
+		}
+	}
+}
+
+func TestCheckAvailabilityOnlyToday(t *testing.T) {
+	f := newFakeWeWork(t)
+	f.Space.Seat.Total = 10
+
+	client, _, _ := newTestBookingDeps(t, f)
+	now := time.Now()
+	year, month, day := now.UTC().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	availability, err := checkAvailability(context.Background(), client, f.AccessToken, f.Space.Location.UUID, today, SpaceFilter{}, now)
+
+	if err != nil || availability.Available || availability.SeatsAvailable == nil || *availability.SeatsAvailable != 0 {
+		t.Errorf("Expected the desk to be full today, but got %+v and %v", availability, err)
+	}
+
+	availability, err = checkAvailability(context.Background(), client, f.AccessToken, f.Space.Location.UUID, today.AddDate(0, 0, 3), SpaceFilter{}, now)
+
+	if err != nil || !availability.Available || availability.SeatsAvailable != nil {
+		t.Errorf("Expected the seats of another day to be unknown, but got %+v and %v", availability, err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"slices"
 	"time"
 
 	"github.com/chromedp/chromedp"
@@ -15,40 +17,17 @@ import (
 const PageLogin = "login"
 const PageReserve = "reserve"
 
-var ErrDateInOlderThanOneMonthFuture = errors.New("date is more than 31 days in the future")
+// Names of the branches of the login, to know from the logs where it went wrong
+const LoginBranchUsername = "username"
+const LoginBranchPrefilledUsername = "prefilled username"
+const LoginBranchLoggedIn = "logged in"
+const LoginBranchWrongPassword = "wrong password"
+const LoginBranchMFA = "mfa"
 
-func login(ctx context.Context, email string, password string) error {
+func login(ctx context.Context, weworkURL string, email string, password string) error {
 	return chromedp.Run(ctx,
 		chromedp.Click(`//button[text()="Member log in"]`, chromedp.BySearch),
-		raceItemsChromeFn(ctx, []BrowserSwitchAction{
-			{
-				Checker: func(ctx context.Context) {
-					chromedp.WaitReady(`input[id="username"][value=""]`, chromedp.ByQuery).Do(ctx)
-				},
-				Action: func(ctx context.Context) error {
-					return chromedp.Run(ctx,
-						chromedp.Sleep(2*time.Second),
-						chromedp.Click(`input[id="username"]`, chromedp.ByQuery),
-						chromedp.Clear(`input[id="username"]`, chromedp.ByQuery),
-						chromedp.SetValue(`input[id="username"]`, email, chromedp.ByQuery),
-						chromedp.ActionFunc(func(ctx context.Context) error {
-							log.Println("Filled email")
-							return nil
-						}),
-						chromedp.Click(`button[type="submit"]`, chromedp.ByQuery),
-					)
-				},
-			},
-			{
-				Checker: func(ctx context.Context) {
-					chromedp.WaitReady(`input[name="username"][readonly]`, chromedp.ByQuery).Do(ctx)
-				},
-				Action: func(ctx context.Context) error {
-					log.Println("Username is already filled")
-					return nil
-				},
-			},
-		}, 5*time.Second),
+		raceItemsChromeFn(ctx, loginUsernameActions(email), 5*time.Second),
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			log.Println("waiting for login form")
 			return nil
@@ -56,9 +35,90 @@ func login(ctx context.Context, email string, password string) error {
 		chromedp.WaitReady(`input[id="password"]`, chromedp.ByQuery),
 		chromedp.SendKeys(`input[id="password"]`, password, chromedp.ByQuery),
 		chromedp.Click(`button[type="submit"]`, chromedp.ByQuery),
+		raceItemsChromeFn(ctx, loginResultActions(weworkURL), 20*time.Second),
 	)
 }
 
+// loginUsernameActions fills the email, unless the login page remembered it
+func loginUsernameActions(email string) []BrowserSwitchAction {
+	return []BrowserSwitchAction{
+		{
+			Name: LoginBranchUsername,
+			Checker: func(ctx context.Context) {
+				chromedp.WaitReady(`input[id="username"][value=""]`, chromedp.ByQuery).Do(ctx)
+			},
+			Action: func(ctx context.Context) error {
+				return chromedp.Run(ctx,
+					chromedp.Sleep(2*time.Second),
+					chromedp.Click(`input[id="username"]`, chromedp.ByQuery),
+					chromedp.Clear(`input[id="username"]`, chromedp.ByQuery),
+					chromedp.SetValue(`input[id="username"]`, email, chromedp.ByQuery),
+					chromedp.ActionFunc(func(ctx context.Context) error {
+						log.Println("Filled email")
+						return nil
+					}),
+					chromedp.Click(`button[type="submit"]`, chromedp.ByQuery),
+				)
+			},
+		},
+		{
+			Name: LoginBranchPrefilledUsername,
+			Checker: func(ctx context.Context) {
+				chromedp.WaitReady(`input[name="username"][readonly]`, chromedp.ByQuery).Do(ctx)
+			},
+			Action: func(ctx context.Context) error {
+				log.Println("Username is already filled")
+				return nil
+			},
+		},
+	}
+}
+
+// loginResultActions tells, once the password is sent, whether WeWork let us in
+func loginResultActions(weworkURL string) []BrowserSwitchAction {
+	return []BrowserSwitchAction{
+		{
+			Name: LoginBranchLoggedIn,
+			Checker: func(ctx context.Context) {
+				// The poll fails when the page navigates, which the redirects after the login do
+				for ctx.Err() == nil {
+					var loggedIn bool
+
+					if err := chromedp.Poll(fmt.Sprintf(`location.origin === %q`, weworkOrigin(weworkURL)), &loggedIn).Do(ctx); err == nil && loggedIn {
+						return
+					}
+
+					select {
+					case <-time.After(100 * time.Millisecond):
+					case <-ctx.Done():
+					}
+				}
+			},
+			Action: func(ctx context.Context) error {
+				log.Println("Logged in")
+				return nil
+			},
+		},
+		{
+			Name: LoginBranchWrongPassword,
+			Checker: func(ctx context.Context) {
+				chromedp.WaitVisible(`#error-element-password`, chromedp.ByQuery).Do(ctx)
+			},
+			Action: func(ctx context.Context) error {
+				return fmt.Errorf("%w: wrong email or password", ErrLoginFailed)
+			},
+		},
+		{
+			Name: LoginBranchMFA,
+			Checker: func(ctx context.Context) {
+				chromedp.WaitReady(`input[name="code"], input[autocomplete="one-time-code"]`, chromedp.ByQuery).Do(ctx)
+			},
+			Action: func(ctx context.Context) error {
+				return ErrMFARequired
+			},
+		}}
+}
+
 func getWeWorkLocationFromCache(ctx context.Context, cacheManager *cache.Cache[[]byte], coworkingLocationID string) (WeWorkLocation, error) {
 	var weworkLocation WeWorkLocation
 
@@ -76,106 +136,480 @@ func getWeWorkLocationFromCache(ctx context.Context, cacheManager *cache.Cache[[
 	return WeWorkLocation{}, errors.New("no cached location found")
 }
 
-func makeBooking(ctx context.Context, coworkingLocationID string, date string, cacheManager *cache.Cache[[]byte]) error {
+const BookingResultBooked = "booked"
+const BookingResultAlreadyBooked = "already_booked"
+const BookingResultTooFar = "too_far"
+const BookingResultFailed = "failed"
+const BookingResultWaitlisted = "waitlisted"
+
+// BookingResult is the outcome of booking a single date
+type BookingResult struct {
+	Date          string `json:"date"`
+	Status        string `json:"status"`
+	Location      string `json:"location,omitempty"`
+	ReservationID string `json:"reservationId,omitempty"`
+	// Only set for partial-day bookings
+	Start       string  `json:"start,omitempty"`
+	End         string  `json:"end,omitempty"`
+	CreditsUsed float64 `json:"creditsUsed,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	// The messages of WeWork when it rejected the booking
+	Details []string `json:"details,omitempty"`
+	// Set when the date was full and is now waiting for a seat
+	WaitlistID uint64 `json:"waitlistId,omitempty"`
+
+	err error
+}
+
+func (r BookingResult) waitlisted(entry WaitlistEntry) BookingResult {
+	r.Status = BookingResultWaitlisted
+	r.WaitlistID = entry.ID
+	r.Error = ""
+	r.err = nil
+
+	return r
+}
+
+// bookDates books every date one after the other with the same token. It stops with
+// ErrTokenRejected as soon as WeWork rejects the token, as every other date would fail too
+func bookDates(ctx context.Context, client *WeWorkClient, bearerToken string, account string, coworkingLocationID string, dates []string, slot TimeSlot, space SpaceFilter, cacheManager *cache.Cache[[]byte], ledger *Ledger) ([]BookingResult, error) {
+	results := make([]BookingResult, 0, len(dates))
+
+	for _, date := range dates {
+		bookingResponse, err := makeBooking(ctx, client, bearerToken, account, coworkingLocationID, date, slot, space, cacheManager, ledger)
+
+		if errors.Is(err, ErrTokenRejected) {
+			return results, err
+		}
+
+		result := BookingResult{
+			Date:          date,
+			Status:        BookingResultBooked,
+			Location:      coworkingLocationID,
+			ReservationID: bookingResponse.ReservationID,
+			CreditsUsed:   bookingResponse.CreditsUsed,
+			Details:       bookingResponse.Errors,
+			err:           err,
+		}
+
+		if !slot.IsWholeDay() {
+			result.Start, result.End = formatSlotTime(slot.Start), formatSlotTime(slot.End)
+		}
+
+		switch {
+		case errors.Is(err, ErrAlreadyBooked):
+			result.Status = BookingResultAlreadyBooked
+		case errors.Is(err, ErrDateInOlderThanOneMonthFuture):
+			result.Status = BookingResultTooFar
+			result.Error = err.Error()
+		case err != nil:
+			result.Status = BookingResultFailed
+			result.Error = err.Error()
+		}
+
+		log.Println("Booking", date, result.Status, result.ReservationID, result.Error)
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func makeBooking(ctx context.Context, client *WeWorkClient, bearerToken string, account string, coworkingLocationID string, date string, slot TimeSlot, space SpaceFilter, cacheManager *cache.Cache[[]byte], ledger *Ledger) (BookingResponse, error) {
+	bookingResponse, err := doBooking(ctx, client, bearerToken, account, coworkingLocationID, date, slot, space, cacheManager, ledger)
+
+	recordBooking(ledger, account, coworkingLocationID, date, space, bookingResponse, err)
+
+	return bookingResponse, err
+}
+
+// recordBooking stores the outcome of a booking attempt in the ledger. A rejected token
+// is not recorded, the booking is tried again with a new one and is not a failed attempt
+func recordBooking(ledger *Ledger, account string, coworkingLocationID string, date string, space SpaceFilter, bookingResponse BookingResponse, err error) {
+	if errors.Is(err, ErrTokenRejected) {
+		return
+	}
+
+	entry := LedgerEntry{
+		Account:       account,
+		Date:          date,
+		LocationID:    coworkingLocationID,
+		Status:        LedgerStatusBooked,
+		ReservationID: bookingResponse.ReservationID,
+		WeworkUUID:    bookingResponse.WeworkUUID,
+		CreditsUsed:   bookingResponse.CreditsUsed,
+	}
+
+	if errors.Is(err, ErrAlreadyBooked) {
+		entry.Status = LedgerStatusAlreadyBooked
+	} else if err != nil {
+		entry.Status = LedgerStatusFailed
+		entry.Error = err.Error()
+	}
+
+	// The booking itself already happened, so a ledger failure is only logged
+	if _, ledgerErr := ledger.Record(entry); ledgerErr != nil {
+		log.Println("Could not record booking in ledger:", ledgerErr)
+	}
+}
+
+// doBooking books the space, unless there is already a reservation for that date in which
+// case ErrAlreadyBooked is returned along with the existing reservation
+func doBooking(ctx context.Context, client *WeWorkClient, bearerToken string, account string, coworkingLocationID string, date string, slot TimeSlot, space SpaceFilter, cacheManager *cache.Cache[[]byte], ledger *Ledger) (BookingResponse, error) {
 	layout := "Jan 2, 2006"
 	// We do not need to check the error as this was already checked
 	d, _ := time.Parse(layout, date)
 
-	now := time.Now()
+	if !isWithinBookingWindow(d, time.Now()) {
+		return BookingResponse{}, ErrDateInOlderThanOneMonthFuture
+	}
+
+	existing, found, err := findExistingBooking(ctx, client, bearerToken, ledger, account, coworkingLocationID, date)
+
+	if err != nil {
+		return BookingResponse{}, err
+	}
 
-	if d.Sub(now) > 31*24*time.Hour {
-		return ErrDateInOlderThanOneMonthFuture
+	if found {
+		log.Println("Already booked for date:", date, "reservation:", existing.ReservationID)
+		return existing, ErrAlreadyBooked
+	}
+
+	// A full space fails before spending a booking attempt, it can then be waitlisted
+	if err := ensureAvailable(ctx, client, bearerToken, coworkingLocationID, d, space, time.Now()); errors.Is(err, ErrNoSeatsAvailable) || errors.Is(err, ErrTokenRejected) {
+		return BookingResponse{}, err
+	} else if err != nil {
+		log.Println("Could not check availability, booking anyway:", err)
+	}
+
+	weworkLocation, err := getBookableSpace(ctx, client, cacheManager, bearerToken, coworkingLocationID, space)
+
+	if err != nil {
+		return BookingResponse{}, err
 	}
 
-	bearerToken, err := getBearerToken(ctx)
+	start, end, err := bookingSlot(d, weworkLocation, slot)
 
 	if err != nil {
+		return BookingResponse{}, err
+	}
+
+	credits := bookingCredits(weworkLocation, start, end, slot.IsWholeDay())
+
+	if err := client.budget.Check(ledger, account, d, credits); err != nil {
+		return BookingResponse{}, err
+	}
+
+	request := newBookingRequest(start, end, weworkLocation, credits)
+
+	var bookingResponse BookingResponse
+
+	// The failed attempt may still have booked the space, it is only sent again once
+	// WeWork tells it was not, otherwise it could be booked and charged twice
+	recheckFailed := false
+	retryable := func(err error) bool {
+		return !recheckFailed && isTemporaryError(err)
+	}
+
+	err = client.retry.DoIf(ctx, "booking "+date, retryable, func(attempt int) error {
+		if attempt > 1 {
+			bookings, err := client.ListBookings(ctx, bearerToken)
+
+			if err != nil {
+				recheckFailed = true
+				return fmt.Errorf("could not check whether the previous attempt booked %s, not booking again: %w", date, err)
+			}
+
+			if booking, found := findBooking(bookings, coworkingLocationID, date, ""); found {
+				log.Println("Previous attempt booked", date, "reservation:", booking.ReservationID)
+				bookingResponse = newBookingResponse(booking)
+				return nil
+			}
+		}
+
+		var err error
+
+		bookingResponse, err = client.CreateBooking(ctx, bearerToken, request)
+
 		return err
+	})
+
+	if err == nil && bookingResponse.CreditsUsed == 0 {
+		bookingResponse.CreditsUsed = credits
+	}
+
+	return bookingResponse, err
+}
+
+// findExistingBooking checks WeWork for a reservation at the location for that date.
+// The ledger is only used when WeWork cannot be reached, as bookings can also be
+// made or cancelled from the WeWork website
+func findExistingBooking(ctx context.Context, client *WeWorkClient, bearerToken string, ledger *Ledger, account string, coworkingLocationID string, date string) (BookingResponse, bool, error) {
+	bookings, err := client.ListBookings(ctx, bearerToken)
+
+	if err == nil {
+		booking, found := findBooking(bookings, coworkingLocationID, date, "")
+
+		return newBookingResponse(booking), found, nil
+	}
+
+	// The booking would be rejected as well
+	if errors.Is(err, ErrTokenRejected) {
+		return BookingResponse{}, false, err
 	}
 
+	log.Println("Could not fetch upcoming bookings, falling back to ledger:", err)
+
+	entry, found, err := ledger.FindBooked(account, coworkingLocationID, date)
+
+	if err != nil {
+		log.Println("Could not read ledger:", err)
+		return BookingResponse{}, false, nil
+	}
+
+	return BookingResponse{
+		BookingStatus: "BookingSuccess",
+		ReservationID: entry.ReservationID,
+		WeworkUUID:    entry.WeworkUUID,
+	}, found, nil
+}
+
+// newBookingResponse describes an existing booking like WeWork answers a new one
+func newBookingResponse(booking WeWorkBooking) BookingResponse {
+	return BookingResponse{
+		BookingStatus: "BookingSuccess",
+		ReservationID: booking.ReservationID,
+		WeworkUUID:    booking.WeworkUUID,
+		CreditsUsed:   booking.CreditsUsed,
+	}
+}
+
+// getWeWorkLocation returns the location from the cache, or fetches it from the API and caches it
+func getWeWorkLocation(ctx context.Context, client *WeWorkClient, cacheManager *cache.Cache[[]byte], bearerToken string, coworkingLocationID string) (WeWorkLocation, error) {
 	// First try to get location from cache
 	weworkLocation, err := getWeWorkLocationFromCache(ctx, cacheManager, coworkingLocationID)
 
+	if err == nil {
+		return weworkLocation, nil
+	}
+
+	// If not in cache, fetch from API
+	log.Println("Fetching location from API")
+	weworkLocation, err = FetchWeWorkLocation(ctx, client, bearerToken, coworkingLocationID)
+
 	if err != nil {
-		// If not in cache, fetch from API
-		log.Println("Fetching location from API")
-		weworkLocation, err = FetchWeWorkLocation(ctx, bearerToken, coworkingLocationID)
+		return WeWorkLocation{}, err
+	}
 
-		if err != nil {
-			return err
+	// Store in cache for 7 days
+	cacheKey := "wework_location_" + coworkingLocationID
+	data, err := json.Marshal(weworkLocation)
+
+	if err == nil {
+		cacheManager.Set(ctx, cacheKey, data, store.WithExpiration(24*time.Hour*7))
+	}
+
+	return weworkLocation, nil
+}
+
+type UpcomingBooking struct {
+	Date          string  `json:"date"`
+	LocationID    string  `json:"locationId"`
+	LocationName  string  `json:"locationName"`
+	ReservationID string  `json:"reservationId"`
+	CreditsUsed   float64 `json:"creditsUsed"`
+}
+
+type LocationSearchResult struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Address        string `json:"address"`
+	City           string `json:"city"`
+	Country        string `json:"country"`
+	Timezone       string `json:"timezone"`
+	SeatsAvailable int    `json:"seatsAvailable"`
+	SeatsTotal     int    `json:"seatsTotal"`
+}
+
+// searchLocations returns a result per location, as a location can have several workspaces
+func searchLocations(ctx context.Context, client *WeWorkClient, bearerToken string, query string) ([]LocationSearchResult, error) {
+	weworkLocations, err := SearchWeWorkLocations(ctx, client, bearerToken, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []LocationSearchResult{}
+
+	for _, weworkLocation := range weworkLocations {
+		if slices.ContainsFunc(results, func(result LocationSearchResult) bool {
+			return result.ID == weworkLocation.Location.UUID
+		}) {
+			continue
 		}
 
-		// Store in cache for 7 days
-		cacheKey := "wework_location_" + coworkingLocationID
-		data, err := json.Marshal(weworkLocation)
+		results = append(results, LocationSearchResult{
+			ID:             weworkLocation.Location.UUID,
+			Name:           weworkLocation.Location.Name,
+			Address:        weworkLocation.Location.Address.Line1,
+			City:           weworkLocation.Location.Address.City,
+			Country:        weworkLocation.Location.Address.Country,
+			Timezone:       weworkLocation.Location.TimeZoneIdentifier,
+			SeatsAvailable: weworkLocation.Seat.Available,
+			SeatsTotal:     weworkLocation.Seat.Total,
+		})
+	}
+
+	return results, nil
+}
+
+// listBookings returns the upcoming bookings of the member with the name of their location
+func listBookings(ctx context.Context, client *WeWorkClient, bearerToken string, cacheManager *cache.Cache[[]byte]) ([]UpcomingBooking, error) {
+	bookings, err := client.ListBookings(ctx, bearerToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	upcomingBookings := make([]UpcomingBooking, 0, len(bookings))
+
+	for _, booking := range bookings {
+		upcomingBooking := UpcomingBooking{
+			Date:          booking.Date().Format("Jan 2, 2006"),
+			LocationID:    booking.LocationID,
+			ReservationID: booking.ReservationID,
+			CreditsUsed:   booking.CreditsUsed,
+		}
 
-		if err == nil {
-			cacheManager.Set(ctx, cacheKey, data, store.WithExpiration(24*time.Hour*7))
+		// A missing location name should not prevent listing the bookings
+		if weworkLocation, err := getWeWorkLocation(ctx, client, cacheManager, bearerToken, booking.LocationID); err == nil {
+			upcomingBooking.LocationName = weworkLocation.Location.Name
+		} else {
+			log.Println("Could not fetch location", booking.LocationID, err)
 		}
+
+		upcomingBookings = append(upcomingBookings, upcomingBooking)
 	}
 
+	return upcomingBookings, nil
+}
+
+// cancelBooking looks up the reservation either by ID or by date and cancels it.
+// It returns the cancelled booking and the amount of credits that were freed
+func cancelBooking(ctx context.Context, client *WeWorkClient, bearerToken string, account string, coworkingLocationID string, date string, reservationID string, ledger *Ledger) (WeWorkBooking, float64, error) {
+	bookings, err := client.ListBookings(ctx, bearerToken)
+
 	if err != nil {
-		return err
+		return WeWorkBooking{}, 0, err
+	}
+
+	booking, found := findBooking(bookings, coworkingLocationID, date, reservationID)
+
+	if !found {
+		return WeWorkBooking{}, 0, ErrBookingNotFound
+	}
+
+	cancelResponse, err := client.CancelBooking(ctx, bearerToken, newCancelBookingRequest(booking))
+
+	if err != nil {
+		return WeWorkBooking{}, 0, err
 	}
 
-	return makeBookingRequest(ctx, bearerToken, d, weworkLocation)
+	if err := ledger.MarkCancelled(account, booking.ReservationID, booking.LocationID, booking.Date().Format("Jan 2, 2006")); err != nil {
+		log.Println("Could not record cancellation in ledger:", err)
+	}
+
+	credits := cancelResponse.CreditsRefunded
+
+	// Not every cancellation reports the refund, fallback on what the booking cost
+	if credits == 0 {
+		credits = booking.CreditsUsed
+	}
+
+	return booking, credits, nil
 }
 
-func getPage(ctx context.Context) (string, error) {
+// findBooking returns the booking matching the reservation ID if given, otherwise
+// the booking at the coworking location for the given date
+func findBooking(bookings []WeWorkBooking, coworkingLocationID string, date string, reservationID string) (WeWorkBooking, bool) {
+	for _, booking := range bookings {
+		if reservationID != "" {
+			if booking.ReservationID == reservationID {
+				return booking, true
+			}
+
+			continue
+		}
+
+		if booking.LocationID == coworkingLocationID && booking.Date().Format("Jan 2, 2006") == date {
+			return booking, true
+		}
+	}
+
+	return WeWorkBooking{}, false
+}
+
+// pageActions tell whether the support page asks to log in, or is ready to read the tokens
+var pageActions = []BrowserSwitchAction{
+	{
+		Name: PageLogin,
+		Checker: func(ctx context.Context) {
+			chromedp.WaitVisible(`//button[text()="Member log in"]`, chromedp.BySearch).Do(ctx)
+		},
+	},
+	{
+		Name: PageReserve,
+		Checker: func(ctx context.Context) {
+			chromedp.WaitReady(`wework-ondemand-support`, chromedp.ByQuery).Do(ctx)
+		},
+	},
+}
+
+func getPage(ctx context.Context, weworkURL string, retry RetryPolicy) (string, error) {
 	currentPage := ""
 
 	run := func(ctx context.Context) error {
 		return chromedp.Run(ctx,
-			chromedp.Navigate(`https://members.wework.com/workplaceone/content2/wework-support`),
+			chromedp.Navigate(weworkSupportPageURL(weworkURL)),
 			chromedp.ActionFunc(func(ctx context.Context) error {
-				ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-				defer cancel()
-
-				resultCh := make(chan string, 1)
-
-				go func() {
-					chromedp.WaitVisible(`//button[text()="Member log in"]`, chromedp.BySearch).Do(ctx)
-					select {
-					case resultCh <- PageLogin:
-					case <-ctx.Done(): // Ensure no goroutine hangs if the context is canceled
-					}
-				}()
-				go func() {
-					chromedp.WaitReady(`wework-ondemand-support`, chromedp.ByQuery).Do(ctx)
-					select {
-					case resultCh <- PageReserve:
-					case <-ctx.Done(): // Ensure no goroutine hangs if the context is canceled
-					}
-				}()
+				page, err := raceItems(ctx, pageActions, 5*time.Second)
 
-				select {
-				case result := <-resultCh:
-					currentPage = result
-				case <-ctx.Done():
-					return errors.New("timed out waiting for page to load")
+				if err != nil {
+					return err
 				}
 
+				currentPage = page
+
 				return nil
 			}))
 	}
 
-	if err := run(ctx); err != nil {
-		log.Println("Error navigating to bookings page:", err)
+	// Any navigation error is worth another try, the page is only read. A Chrome that
+	// failed to start is not, chromedp cannot allocate a browser twice for the same tab
+	retryable := func(err error) bool {
+		c := chromedp.FromContext(ctx)
 
-		// Retry one more time
-		return currentPage, run(ctx)
+		return isNavigationRetryable(err) && c != nil && c.Browser != nil
 	}
 
-	return currentPage, nil
+	err := retry.DoIf(ctx, "loading the bookings page", retryable, func(int) error {
+		return run(ctx)
+	})
+
+	return currentPage, err
 }
 
-// This holds the checker and the action that should be done when true
+// This holds the checker and the action that should be done when true. The name
+// tells which one was picked, in the logs and in the tests
 type BrowserSwitchAction struct {
+	Name    string
 	Checker func(ctx context.Context)
-	Action  func(ctx context.Context) error
+	// Optional, when the branch only has to be known
+	Action func(ctx context.Context) error
 }
 
-func raceItems(ctx context.Context, actions []BrowserSwitchAction, timeout time.Duration) error {
+// raceItems runs the action of the first checker to return and returns its name
+func raceItems(ctx context.Context, actions []BrowserSwitchAction, timeout time.Duration) (string, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -184,6 +618,12 @@ func raceItems(ctx context.Context, actions []BrowserSwitchAction, timeout time.
 	for i, action := range actions {
 		go func(ctx context.Context) {
 			action.Checker(ctx)
+
+			// The checker gave up because of the timeout, it did not find anything
+			if ctx.Err() != nil {
+				return
+			}
+
 			select {
 			case resultCh <- i:
 			case <-ctx.Done(): // Ensure no goroutine hangs if the context is canceled
@@ -193,14 +633,23 @@ func raceItems(ctx context.Context, actions []BrowserSwitchAction, timeout time.
 
 	select {
 	case result := <-resultCh:
-		return actions[result].Action(ctx)
+		action := actions[result]
+
+		log.Println("Browser switched to", action.Name)
+
+		if action.Action == nil {
+			return action.Name, nil
+		}
+
+		return action.Name, action.Action(ctx)
 	case <-ctx.Done():
-		return errors.New("timed out waiting for page to load")
+		return "", ErrPageTimeout
 	}
 }
 
 func raceItemsChromeFn(ctx context.Context, actions []BrowserSwitchAction, timeout time.Duration) chromedp.ActionFunc {
 	return chromedp.ActionFunc(func(ctx context.Context) error {
-		return raceItems(ctx, actions, timeout)
+		_, err := raceItems(ctx, actions, timeout)
+		return err
 	})
 }
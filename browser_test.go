@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestFindBooking(t *testing.T) {
+	bookings := []WeWorkBooking{
+		{ReservationID: "r1", LocationID: "loc-a", StartTime: time.Date(2025, 2, 18, 4, 0, 0, 0, time.UTC), TimezoneIana: "Europe/Paris"},
+		{ReservationID: "r2", LocationID: "loc-b", StartTime: time.Date(2025, 2, 18, 4, 0, 0, 0, time.UTC), TimezoneIana: "Europe/Paris"},
+		// 23:00 in UTC is already the next day in Paris
+		{ReservationID: "r3", LocationID: "loc-a", StartTime: time.Date(2025, 2, 19, 23, 0, 0, 0, time.UTC), TimezoneIana: "Europe/Paris"},
+	}
+
+	tests := []struct {
+		locationID    string
+		date          string
+		reservationID string
+		expected      string
+		found         bool
+	}{
+		{"loc-a", "Feb 18, 2025", "", "r1", true},
+		{"loc-b", "Feb 18, 2025", "", "r2", true},
+		{"loc-a", "Feb 20, 2025", "", "r3", true},
+		{"loc-a", "Feb 19, 2025", "", "", false},
+		{"loc-a", "", "r2", "r2", true},
+		{"loc-a", "", "unknown", "", false},
+	}
+
+	for _, test := range tests {
+		booking, found := findBooking(bookings, test.locationID, test.date, test.reservationID)
+
+		if found != test.found {
+			t.Errorf("For %s/%s/%s, expected found %v, but got %v", test.locationID, test.date, test.reservationID, test.found, found)
+		}
+
+		if booking.ReservationID != test.expected {
+			t.Errorf("For %s/%s/%s, expected %s, but got %s", test.locationID, test.date, test.reservationID, test.expected, booking.ReservationID)
+		}
+	}
+}
+
+func TestRaceItems(t *testing.T) {
+	ctx := context.Background()
+
+	waitForCancel := func(ctx context.Context) { <-ctx.Done() }
+	ran := ""
+
+	actions := []BrowserSwitchAction{
+		{Name: "never", Checker: waitForCancel, Action: func(context.Context) error { ran = "never"; return nil }},
+		{Name: "found", Checker: func(context.Context) { time.Sleep(10 * time.Millisecond) }, Action: func(context.Context) error { ran = "found"; return nil }},
+	}
+
+	if name, err := raceItems(ctx, actions, time.Second); name != "found" || err != nil || ran != "found" {
+		t.Errorf("Expected the found branch to run, but got %q %q %v", name, ran, err)
+	}
+
+	// The checkers return once the context is done, that is not a match
+	ran = ""
+
+	if name, err := raceItems(ctx, actions[:1], 20*time.Millisecond); name != "" || !errors.Is(err, ErrPageTimeout) || ran != "" {
+		t.Errorf("Expected ErrPageTimeout, but got %q %q %v", name, ran, err)
+	}
+}
+
+// newBrowserTab opens a tab in a headless Chrome, the test is skipped when Chrome cannot be found
+func newBrowserTab(t *testing.T) context.Context {
+	if testing.Short() {
+		t.Skip("Skipping the browser test in short mode")
+	}
+
+	chromePath := findChrome()
+
+	if chromePath == "" {
+		t.Skip("Chrome is not installed, set WEBOOK_CHROME_PATH to run the browser tests")
+	}
+
+	allocCtx, cancel := BrowserConfig{Headless: true, ExecPath: chromePath}.NewAllocator(t.TempDir())
+	t.Cleanup(cancel)
+
+	ctx, cancelTab := chromedp.NewContext(allocCtx)
+	t.Cleanup(cancelTab)
+
+	return ctx
+}
+
+// serveFixture answers every request with the page from testdata/login
+func serveFixture(t *testing.T, fixture string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join("testdata", "login", fixture+".html"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func TestGetPage(t *testing.T) {
+	ctx := newBrowserTab(t)
+
+	retry := RetryPolicy{MaxAttempts: 1, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	tests := []struct {
+		fixture  string
+		expected string
+		err      error
+	}{
+		{"wework_login", PageLogin, nil},
+		{"wework_support", PageReserve, nil},
+		{"wework_support_slow", PageReserve, nil},
+		{"wework_blank", "", ErrPageTimeout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			page, err := getPage(ctx, serveFixture(t, test.fixture), retry)
+
+			if page != test.expected || !errors.Is(err, test.err) {
+				t.Errorf("Expected page %q and %v, but got %q and %v", test.expected, test.err, page, err)
+			}
+		})
+	}
+}
+
+func TestLoginBranches(t *testing.T) {
+	ctx := newBrowserTab(t)
+
+	tests := []struct {
+		fixture string
+		// The username step, otherwise the step after the password was sent
+		usernameStep bool
+		// Whether the fixture is served from the WeWork domain
+		onWeWork bool
+		expected string
+		err      error
+	}{
+		{fixture: "auth0_username", usernameStep: true, expected: LoginBranchUsername},
+		{fixture: "auth0_prefilled", usernameStep: true, expected: LoginBranchPrefilledUsername},
+		{fixture: "wework_support", onWeWork: true, expected: LoginBranchLoggedIn},
+		{fixture: "auth0_wrong_password", expected: LoginBranchWrongPassword, err: ErrLoginFailed},
+		{fixture: "auth0_mfa", expected: LoginBranchMFA, err: ErrMFARequired},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			url := serveFixture(t, test.fixture)
+
+			if err := chromedp.Run(ctx, chromedp.Navigate(url)); err != nil {
+				t.Fatal(err)
+			}
+
+			actions := loginUsernameActions("member@example.com")
+
+			if !test.usernameStep {
+				weworkURL := DefaultWeWorkURL
+
+				if test.onWeWork {
+					weworkURL = url
+				}
+
+				actions = loginResultActions(weworkURL)
+			}
+
+			name, err := raceItems(ctx, actions, 5*time.Second)
+
+			if name != test.expected || !errors.Is(err, test.err) {
+				t.Errorf("Expected branch %q and %v, but got %q and %v", test.expected, test.err, name, err)
+			}
+		})
+	}
+}
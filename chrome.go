@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// The remote Chrome may be restarting, it is waited for before giving up on a login
+const remoteConnectAttempts = 5
+const remoteConnectDelay = 3 * time.Second
+
+const healthCheckTimeout = 5 * time.Second
+
+// BrowserConfig controls how Chrome is started, so the same binary runs with a visible
+// browser in local development and headless in the container
+type BrowserConfig struct {
+	Headless bool `yaml:"headless"`
+	// Profile of the account from the WEWORK_* variables, the other accounts get <profileDir>-<name>
+	ProfileDir string `yaml:"profileDir"`
+	// Found in the usual locations when empty
+	ExecPath string `yaml:"execPath"`
+	// DevTools websocket URL of an already running Chrome, e.g. ws://chrome:9222
+	RemoteURL  string `yaml:"remoteUrl"`
+	Proxy      string `yaml:"proxy"`
+	UserAgent  string `yaml:"userAgent"`
+	WindowSize string `yaml:"windowSize"`
+}
+
+// applyEnv overrides the browser settings with the WEBOOK_* variables
+func (b *BrowserConfig) applyEnv() error {
+	if headless := os.Getenv("WEBOOK_HEADLESS"); headless != "" {
+		value, err := strconv.ParseBool(headless)
+
+		if err != nil {
+			return fmt.Errorf("invalid WEBOOK_HEADLESS: %w", err)
+		}
+
+		b.Headless = value
+	}
+
+	for variable, setting := range map[string]*string{
+		"WEBOOK_PROFILE_DIR": &b.ProfileDir,
+		"WEBOOK_CHROME_PATH": &b.ExecPath,
+		"WEBOOK_CHROME_URL":  &b.RemoteURL,
+		"WEBOOK_PROXY":       &b.Proxy,
+		"WEBOOK_USER_AGENT":  &b.UserAgent,
+		"WEBOOK_WINDOW_SIZE": &b.WindowSize,
+	} {
+		if value := os.Getenv(variable); value != "" {
+			*setting = value
+		}
+	}
+
+	return nil
+}
+
+// parseWindowSize reads sizes like 1280x800
+func parseWindowSize(size string) (int, int, error) {
+	width, height, found := strings.Cut(strings.ToLower(size), "x")
+
+	if !found {
+		return 0, 0, fmt.Errorf("invalid window size %q, expected <width>x<height>", size)
+	}
+
+	w, err := strconv.Atoi(strings.TrimSpace(width))
+
+	if err != nil || w <= 0 {
+		return 0, 0, fmt.Errorf("invalid window width in %q", size)
+	}
+
+	h, err := strconv.Atoi(strings.TrimSpace(height))
+
+	if err != nil || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid window height in %q", size)
+	}
+
+	return w, h, nil
+}
+
+func (b BrowserConfig) allocatorOptions(profileDir string) []chromedp.ExecAllocatorOption {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", b.Headless),
+		chromedp.UserDataDir(profileDir),
+	)
+
+	if b.ExecPath != "" {
+		opts = append(opts, chromedp.ExecPath(b.ExecPath))
+	}
+
+	if b.Proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(b.Proxy))
+	}
+
+	if b.UserAgent != "" {
+		opts = append(opts, chromedp.UserAgent(b.UserAgent))
+	}
+
+	// The size is checked when the config is loaded
+	if width, height, err := parseWindowSize(b.WindowSize); err == nil {
+		opts = append(opts, chromedp.WindowSize(width, height))
+	}
+
+	return opts
+}
+
+// NewAllocator starts a local Chrome using the profile, or connects to the remote one.
+// A remote Chrome has a single profile, so the accounts get their own browser context instead
+func (b BrowserConfig) NewAllocator(profileDir string) (context.Context, context.CancelFunc) {
+	if b.Remote() {
+		return chromedp.NewRemoteAllocator(context.Background(), b.RemoteURL)
+	}
+
+	return chromedp.NewExecAllocator(context.Background(), b.allocatorOptions(profileDir)...)
+}
+
+// Remote is true when the service connects to a Chrome running elsewhere
+func (b BrowserConfig) Remote() bool {
+	return b.RemoteURL != ""
+}
+
+// CheckHealth makes sure the remote Chrome answers on its DevTools endpoint. A local
+// Chrome is started for every login, so there is nothing to check
+func (b BrowserConfig) CheckHealth(ctx context.Context) error {
+	if !b.Remote() {
+		return nil
+	}
+
+	versionURL, err := url.Parse(b.RemoteURL)
+
+	if err != nil {
+		return err
+	}
+
+	switch versionURL.Scheme {
+	case "ws":
+		versionURL.Scheme = "http"
+	case "wss":
+		versionURL.Scheme = "https"
+	}
+
+	versionURL.Path = "/json/version"
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	if err := resolveHost(ctx, versionURL); err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, versionURL.String(), nil)
+
+	if err != nil {
+		return err
+	}
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote Chrome answered %s", response.Status)
+	}
+
+	return nil
+}
+
+// resolveHost replaces the hostname of the URL with its IP. Chrome DevTools refuses
+// any Host header that is not an IP or localhost, like chrome:9222 in docker compose
+func resolveHost(ctx context.Context, u *url.URL) error {
+	hostname, port := u.Hostname(), u.Port()
+
+	if net.ParseIP(hostname) != nil {
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, hostname)
+
+	if err != nil {
+		return err
+	}
+
+	if len(addresses) == 0 {
+		return fmt.Errorf("no address found for %s", hostname)
+	}
+
+	ip := addresses[0].IP
+
+	for _, address := range addresses {
+		if address.IP.To4() != nil {
+			ip = address.IP
+			break
+		}
+	}
+
+	u.Host = ip.String()
+
+	if ip.To4() == nil {
+		u.Host = "[" + ip.String() + "]"
+	}
+
+	if port != "" {
+		u.Host = net.JoinHostPort(ip.String(), port)
+	}
+
+	return nil
+}
+
+// waitForRemote gives a restarting remote Chrome some time to come back. Every login
+// opens a new connection, so once it answers again nothing else needs to be reset
+func (b BrowserConfig) waitForRemote(ctx context.Context) error {
+	var err error
+
+	for attempt := 1; attempt <= remoteConnectAttempts; attempt++ {
+		if err = b.CheckHealth(ctx); err == nil {
+			return nil
+		}
+
+		log.Printf("Remote Chrome is not reachable (attempt %d/%d): %s", attempt, remoteConnectAttempts, err)
+
+		select {
+		case <-time.After(remoteConnectDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return errors.Join(errors.New("remote Chrome is not reachable"), err)
+}
+
+// contextOptions are the options of the tabs opened by the accounts
+func (b BrowserConfig) contextOptions() []chromedp.ContextOption {
+	if b.Remote() {
+		// Like an incognito window, cookies are dropped when the tab is closed
+		return []chromedp.ContextOption{chromedp.WithNewBrowserContext()}
+	}
+
+	return nil
+}
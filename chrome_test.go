@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBrowserCheckHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json/version" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(`{"webSocketDebuggerUrl": "ws://127.0.0.1:9222/devtools/browser/id"}`))
+	}))
+
+	remoteURL := "ws://" + strings.TrimPrefix(server.URL, "http://")
+
+	if err := (BrowserConfig{}).CheckHealth(context.Background()); err != nil {
+		t.Errorf("Did not expect error for a local Chrome, but got %v", err)
+	}
+
+	browser := BrowserConfig{RemoteURL: remoteURL}
+
+	if err := browser.CheckHealth(context.Background()); err != nil {
+		t.Errorf("Expected the remote Chrome to be healthy, but got %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	registerHealthHandler(browser)(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected 200, but got %d", recorder.Code)
+	}
+
+	server.Close()
+
+	if err := browser.CheckHealth(context.Background()); err == nil {
+		t.Errorf("Expected error once the remote Chrome is gone")
+	}
+
+	recorder = httptest.NewRecorder()
+
+	registerHealthHandler(browser)(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, but got %d", recorder.Code)
+	}
+}
+
+func TestBrowserCheckHealthResolvesHostname(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Like Chrome DevTools, only answer when the Host header is an IP
+		if host, _, _ := net.SplitHostPort(r.Host); net.ParseIP(host) == nil {
+			http.Error(w, "Host header is specified and is not an IP address or localhost.", http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(`{"webSocketDebuggerUrl": "ws://127.0.0.1:9222/devtools/browser/id"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	browser := BrowserConfig{RemoteURL: "ws://localhost:" + port}
+
+	if err := browser.CheckHealth(context.Background()); err != nil {
+		t.Errorf("Expected the remote Chrome to be reached through its IP, but got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// runLocationsCommand prints the locations matching the query, e.g. `webook locations 115 Broadway`
+func runLocationsCommand(account *Account, client *WeWorkClient, args []string) error {
+	query := strings.Join(args, " ")
+
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("usage: %s locations <name or city>", os.Args[0])
+	}
+
+	var results []LocationSearchResult
+
+	ctx := context.Background()
+
+	if err := account.WithToken(ctx, func(bearerToken string) error {
+		var err error
+
+		results, err = searchLocations(ctx, client, bearerToken, query)
+
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No location found for", query)
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "ID\tNAME\tADDRESS\tTIMEZONE\tSEATS")
+
+	for _, result := range results {
+		fmt.Fprintf(writer, "%s\t%s\t%s, %s\t%s\t%d/%d\n", result.ID, result.Name, result.Address, result.City, result.Timezone, result.SeatsAvailable, result.SeatsTotal)
+	}
+
+	return writer.Flush()
+}
+
+// runLoginCommand logs every account in with the browser once, so the server can then
+// renew the access tokens with the stored refresh tokens, e.g. `webook login`
+func runLoginCommand(accounts *Accounts, credentials *CredentialStore) error {
+	if !credentials.Persistent() {
+		return fmt.Errorf("WEBOOK_SECRET_KEY must be set to store the refresh tokens")
+	}
+
+	for _, account := range accounts.All() {
+		if err := account.Login(); err != nil {
+			return fmt.Errorf("could not log in %s: %w", account.Name, err)
+		}
+
+		log.Println("Stored the refresh token of", account.Name)
+	}
+
+	return nil
+}
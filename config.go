@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultAccountName = "default"
+
+// Account names end up in the browser profile paths
+var accountNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var envReferenceRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvReferences replaces the ${VARIABLES} set in the environment. Anything else is
+// kept as is, so a $ in a password or a key is not lost
+func expandEnvReferences(data string) string {
+	return envReferenceRegexp.ReplaceAllStringFunc(data, func(reference string) string {
+		if value, ok := os.LookupEnv(envReferenceRegexp.FindStringSubmatch(reference)[1]); ok {
+			return value
+		}
+
+		return reference
+	})
+}
+
+// Config is read from the optional YAML file pointed by WEBOOK_CONFIG, environment
+// variables take precedence over the file
+type Config struct {
+	Accounts          []AccountConfig   `yaml:"accounts"`
+	APIKeys           []APIKeyConfig    `yaml:"apiKeys"`
+	LocationAliases   map[string]string `yaml:"locationAliases"`
+	LedgerPath        string            `yaml:"ledgerPath"`
+	SchedulerInterval time.Duration     `yaml:"schedulerInterval"`
+	// How often the availability of the waitlisted dates is checked
+	WaitlistInterval time.Duration `yaml:"waitlistInterval"`
+	// Notified of the waitlisted dates that were booked, expired or failed
+	NotifyURL string        `yaml:"notifyUrl"`
+	Browser   BrowserConfig `yaml:"browser"`
+	Retry     RetryPolicy   `yaml:"retry"`
+	Credits   CreditBudget  `yaml:"credits"`
+	// Encrypts the refresh tokens stored in the ledger
+	SecretKey string `yaml:"secretKey"`
+	// Where WeWork is, only changed to run against a stand-in
+	WeWorkURL string `yaml:"weworkUrl"`
+	// Logs the requests to WeWork and their responses, without the tokens
+	Debug bool `yaml:"debug"`
+}
+
+type AccountConfig struct {
+	Name       string `yaml:"name"`
+	Email      string `yaml:"email"`
+	Password   string `yaml:"password"`
+	LocationID string `yaml:"locationId"`
+	ProfileDir string `yaml:"profileDir"`
+}
+
+func loadConfig() (Config, error) {
+	var config Config
+
+	if path := os.Getenv("WEBOOK_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			return Config{}, err
+		}
+
+		// Allows keeping the passwords out of the file, e.g. password: ${ALICE_PASSWORD}
+		if err := yaml.Unmarshal([]byte(expandEnvReferences(string(data))), &config); err != nil {
+			return Config{}, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return Config{}, err
+	}
+
+	if err := config.validate(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// applyEnv adds the account from the WEWORK_* variables and overrides the other settings
+func (c *Config) applyEnv() error {
+	if email := os.Getenv("WEWORK_EMAIL"); email != "" {
+		c.Accounts = append([]AccountConfig{{
+			Name:       DefaultAccountName,
+			Email:      email,
+			Password:   os.Getenv("WEWORK_PASSWORD"),
+			LocationID: os.Getenv("WEWORK_COWORKING_LOCATION_ID"),
+		}}, c.Accounts...)
+	}
+
+	aliases, err := parseLocationAliases(os.Getenv("WEWORK_LOCATION_ALIASES"))
+
+	if err != nil {
+		return fmt.Errorf("invalid WEWORK_LOCATION_ALIASES: %w", err)
+	}
+
+	if c.LocationAliases == nil {
+		c.LocationAliases = map[string]string{}
+	}
+
+	for name, id := range aliases {
+		c.LocationAliases[name] = id
+	}
+
+	// A single key with every scope, for simple setups
+	if key := os.Getenv("WEBOOK_API_KEY"); key != "" {
+		c.APIKeys = append(c.APIKeys, APIKeyConfig{
+			Name:   "env",
+			Key:    key,
+			Scopes: AllScopes,
+		})
+	}
+
+	if ledgerPath := os.Getenv("WEBOOK_LEDGER_PATH"); ledgerPath != "" {
+		c.LedgerPath = ledgerPath
+	}
+
+	if secretKey := os.Getenv("WEBOOK_SECRET_KEY"); secretKey != "" {
+		c.SecretKey = secretKey
+	}
+
+	if weworkURL := os.Getenv("WEBOOK_WEWORK_URL"); weworkURL != "" {
+		c.WeWorkURL = weworkURL
+	}
+
+	if debug := os.Getenv("WEBOOK_DEBUG"); debug != "" {
+		value, err := strconv.ParseBool(debug)
+
+		if err != nil {
+			return fmt.Errorf("invalid WEBOOK_DEBUG: %w", err)
+		}
+
+		c.Debug = value
+	}
+
+	if err := c.Browser.applyEnv(); err != nil {
+		return err
+	}
+
+	if err := c.Retry.applyEnv(); err != nil {
+		return err
+	}
+
+	if err := c.Credits.applyEnv(); err != nil {
+		return err
+	}
+
+	if interval := os.Getenv("WEBOOK_SCHEDULER_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+
+		if err != nil {
+			return fmt.Errorf("invalid WEBOOK_SCHEDULER_INTERVAL: %w", err)
+		}
+
+		c.SchedulerInterval = d
+	}
+
+	if interval := os.Getenv("WEBOOK_WAITLIST_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+
+		if err != nil {
+			return fmt.Errorf("invalid WEBOOK_WAITLIST_INTERVAL: %w", err)
+		}
+
+		c.WaitlistInterval = d
+	}
+
+	if notifyURL := os.Getenv("WEBOOK_NOTIFY_URL"); notifyURL != "" {
+		c.NotifyURL = notifyURL
+	}
+
+	return nil
+}
+
+// validate checks the config and fills the defaults
+func (c *Config) validate() error {
+	if len(c.Accounts) == 0 {
+		return errors.New("WEWORK_EMAIL and WEWORK_PASSWORD must be set, or accounts configured in WEBOOK_CONFIG")
+	}
+
+	if c.WeWorkURL == "" {
+		c.WeWorkURL = DefaultWeWorkURL
+	}
+
+	c.WeWorkURL = strings.TrimSuffix(c.WeWorkURL, "/")
+
+	if u, err := url.Parse(c.WeWorkURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid WeWork URL %q", c.WeWorkURL)
+	}
+
+	if c.Browser.ProfileDir == "" {
+		c.Browser.ProfileDir = "./chrome-data"
+	}
+
+	if c.Browser.WindowSize != "" {
+		if _, _, err := parseWindowSize(c.Browser.WindowSize); err != nil {
+			return err
+		}
+	}
+
+	names := map[string]bool{}
+
+	for i := range c.Accounts {
+		account := &c.Accounts[i]
+
+		if account.Name == "" || account.Email == "" || account.Password == "" {
+			return fmt.Errorf("account %d must have a name, an email and a password", i+1)
+		}
+
+		if !accountNameRegexp.MatchString(account.Name) {
+			return fmt.Errorf("invalid account name %q, only letters, digits, - and _ are allowed", account.Name)
+		}
+
+		if names[account.Name] {
+			return fmt.Errorf("account %s is configured twice", account.Name)
+		}
+
+		names[account.Name] = true
+		account.LocationID = normalizeLocationID(account.LocationID)
+
+		// Every account gets its own browser profile so cookies and tokens are never shared
+		if account.ProfileDir == "" && account.Name == DefaultAccountName {
+			account.ProfileDir = c.Browser.ProfileDir
+		} else if account.ProfileDir == "" {
+			account.ProfileDir = c.Browser.ProfileDir + "-" + account.Name
+		}
+	}
+
+	keyNames := map[string]bool{}
+
+	for i, key := range c.APIKeys {
+		if key.Name == "" || (key.Key == "" && key.Secret == "") {
+			return fmt.Errorf("API key %d must have a name, and a key or a secret", i+1)
+		}
+
+		if keyNames[key.Name] {
+			return fmt.Errorf("API key %s is configured twice", key.Name)
+		}
+
+		keyNames[key.Name] = true
+
+		if key.Account != "" && !names[key.Account] {
+			return fmt.Errorf("API key %s is bound to unknown account %s", key.Name, key.Account)
+		}
+
+		if len(key.Scopes) == 0 {
+			return fmt.Errorf("API key %s must have at least one scope", key.Name)
+		}
+
+		for _, scope := range key.Scopes {
+			if !slices.Contains(AllScopes, scope) {
+				return fmt.Errorf("API key %s has unknown scope %s, expected one of %v", key.Name, scope, AllScopes)
+			}
+		}
+	}
+
+	aliases, err := normalizeLocationAliases(c.LocationAliases)
+
+	if err != nil {
+		return err
+	}
+
+	c.LocationAliases = aliases
+
+	if c.LedgerPath == "" {
+		c.LedgerPath = "./webook.db"
+	}
+
+	c.Retry = c.Retry.withDefaults()
+
+	if err := c.Credits.validate(); err != nil {
+		return err
+	}
+
+	if c.SchedulerInterval <= 0 {
+		c.SchedulerInterval = time.Hour
+	}
+
+	if c.WaitlistInterval <= 0 {
+		c.WaitlistInterval = 5 * time.Minute
+	}
+
+	if c.NotifyURL != "" {
+		if u, err := url.Parse(c.NotifyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid notify URL %q", c.NotifyURL)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webook.yaml")
+
+	if err := os.WriteFile(path, []byte(`
+accounts:
+  - name: alice
+    email: alice@example.com
+    password: ${ALICE_PASSWORD}
+    locationId: 11111111-2222-3333-4444-555555555555
+locationAliases:
+  Broadway: 11111111-2222-3333-4444-555555555555
+apiKeys:
+  - name: cron
+    key: pa$$word$HOME${WEBOOK_UNSET_VARIABLE}
+    scopes: [read]
+schedulerInterval: 30m
+browser:
+  profileDir: /data/chrome
+  windowSize: 1280x800
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("WEBOOK_CONFIG", path)
+	t.Setenv("ALICE_PASSWORD", "secret")
+	t.Setenv("WEWORK_EMAIL", "bob@example.com")
+	t.Setenv("WEWORK_PASSWORD", "hunter2")
+	t.Setenv("WEWORK_COWORKING_LOCATION_ID", "AAAAAAAA-BBBB-CCCC-DDDD-EEEEEEEEEEEE")
+	t.Setenv("WEWORK_LOCATION_ALIASES", "soho=aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee")
+	t.Setenv("WEBOOK_LEDGER_PATH", "")
+	t.Setenv("WEBOOK_SCHEDULER_INTERVAL", "")
+	t.Setenv("WEBOOK_HEADLESS", "true")
+	t.Setenv("WEBOOK_PROFILE_DIR", "")
+	t.Setenv("WEBOOK_CHROME_URL", "ws://chrome:9222")
+	t.Setenv("WEBOOK_WINDOW_SIZE", "")
+	t.Setenv("WEBOOK_WEWORK_URL", "")
+
+	config, err := loadConfig()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.Accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, but got %d", len(config.Accounts))
+	}
+
+	if account := config.Accounts[0]; account.Name != DefaultAccountName || account.Email != "bob@example.com" || account.ProfileDir != "/data/chrome" || account.LocationID != "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee" {
+		t.Errorf("Unexpected default account: %+v", account)
+	}
+
+	if account := config.Accounts[1]; account.Name != "alice" || account.Password != "secret" || account.ProfileDir != "/data/chrome-alice" {
+		t.Errorf("Unexpected account from file: %+v", account)
+	}
+
+	if len(config.APIKeys) != 1 || config.APIKeys[0].Key != "pa$$word$HOME${WEBOOK_UNSET_VARIABLE}" {
+		t.Errorf("Expected only the set ${VARIABLES} to be replaced, but got %+v", config.APIKeys)
+	}
+
+	if config.LocationAliases["broadway"] == "" || config.LocationAliases["soho"] == "" {
+		t.Errorf("Expected aliases from the file and the env, but got %v", config.LocationAliases)
+	}
+
+	if config.SchedulerInterval != 30*time.Minute || config.LedgerPath != "./webook.db" {
+		t.Errorf("Unexpected settings: %s %s", config.SchedulerInterval, config.LedgerPath)
+	}
+
+	if browser := config.Browser; !browser.Headless || browser.RemoteURL != "ws://chrome:9222" || browser.WindowSize != "1280x800" {
+		t.Errorf("Unexpected browser settings: %+v", browser)
+	}
+
+	if config.WeWorkURL != DefaultWeWorkURL {
+		t.Errorf("Expected the WeWork URL to default to %s, but got %s", DefaultWeWorkURL, config.WeWorkURL)
+	}
+
+	t.Setenv("WEBOOK_WEWORK_URL", "http://localhost:8081/")
+
+	if config, err := loadConfig(); err != nil || config.WeWorkURL != "http://localhost:8081" {
+		t.Errorf("Expected the WeWork URL from the env, but got %q and %v", config.WeWorkURL, err)
+	}
+
+	t.Setenv("WEBOOK_WEWORK_URL", "localhost")
+
+	if _, err := loadConfig(); err == nil {
+		t.Errorf("Expected error for a WeWork URL without scheme")
+	}
+
+	t.Setenv("WEBOOK_WEWORK_URL", "")
+
+	if err := os.WriteFile(path, []byte("accounts:\n  - name: ../x\n    email: x@example.com\n    password: x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadConfig(); err == nil {
+		t.Errorf("Expected error for an account name escaping the profile directory")
+	}
+
+	t.Setenv("WEBOOK_CONFIG", "")
+	t.Setenv("WEBOOK_WINDOW_SIZE", "big")
+
+	if _, err := loadConfig(); err == nil {
+		t.Errorf("Expected error for an invalid window size")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/eko/gocache/lib/v4/cache"
+)
+
+const OverBudgetRefuse = "refuse"
+const OverBudgetWarn = "warn"
+
+// CreditBudget caps the credits the bot spends for each account in a month, the month
+// of the booked date as WeWork gives the credits per month
+type CreditBudget struct {
+	// No budget when 0
+	Monthly float64 `yaml:"monthlyBudget"`
+	// refuse, the default, or warn to only log the bookings over budget
+	OverBudget string `yaml:"overBudget"`
+}
+
+// applyEnv overrides the budget with WEBOOK_MONTHLY_BUDGET and WEBOOK_OVER_BUDGET
+func (b *CreditBudget) applyEnv() error {
+	if monthly := os.Getenv("WEBOOK_MONTHLY_BUDGET"); monthly != "" {
+		value, err := strconv.ParseFloat(monthly, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid WEBOOK_MONTHLY_BUDGET: %w", err)
+		}
+
+		b.Monthly = value
+	}
+
+	if overBudget := os.Getenv("WEBOOK_OVER_BUDGET"); overBudget != "" {
+		b.OverBudget = overBudget
+	}
+
+	return nil
+}
+
+func (b *CreditBudget) validate() error {
+	if b.Monthly < 0 {
+		return fmt.Errorf("invalid monthly budget %v", b.Monthly)
+	}
+
+	switch b.OverBudget {
+	case "":
+		b.OverBudget = OverBudgetRefuse
+	case OverBudgetRefuse, OverBudgetWarn:
+	default:
+		return fmt.Errorf("invalid overBudget %q, expected %s or %s", b.OverBudget, OverBudgetRefuse, OverBudgetWarn)
+	}
+
+	return nil
+}
+
+// Check returns ErrBudgetExceeded when booking the date for the credits would spend
+// more than the monthly budget of the account
+func (b CreditBudget) Check(ledger *Ledger, account string, date time.Time, credits float64) error {
+	if b.Monthly == 0 {
+		return nil
+	}
+
+	spent, err := ledger.CreditsSpent(account, date)
+
+	if err != nil {
+		return err
+	}
+
+	if spent+credits <= b.Monthly {
+		return nil
+	}
+
+	err = fmt.Errorf("%w: %s already spent %v of %v credits in %s, the booking costs %v", ErrBudgetExceeded, account, spent, b.Monthly, date.Format("January 2006"), credits)
+
+	if b.OverBudget == OverBudgetWarn {
+		log.Println("WARNING:", err)
+		return nil
+	}
+
+	return err
+}
+
+// CreditsReport is what the bot spent for the account in the month, from the ledger, so
+// the bookings made outside of the bot are not counted. It has no balance: reading it from
+// WeWork was left out until the endpoint the website reads it from is known
+type CreditsReport struct {
+	Account string  `json:"account"`
+	Month   string  `json:"month"`
+	Spent   float64 `json:"spent"`
+	Budget  float64 `json:"budget,omitempty"`
+	// What is left of the budget, when there is one
+	Remaining *float64 `json:"remaining,omitempty"`
+	// The cost of booking the requested date, when one is given
+	Cost *float64 `json:"cost,omitempty"`
+}
+
+// bookingCost is what booking the date at the location would cost, priced like doBooking does
+func bookingCost(ctx context.Context, client *WeWorkClient, bearerToken string, cacheManager *cache.Cache[[]byte], locationID string, date time.Time, slot TimeSlot, space SpaceFilter) (float64, error) {
+	weworkLocation, err := getBookableSpace(ctx, client, cacheManager, bearerToken, locationID, space)
+
+	if err != nil {
+		return 0, err
+	}
+
+	start, end, err := bookingSlot(date, weworkLocation, slot)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return bookingCredits(weworkLocation, start, end, slot.IsWholeDay()), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreditBudgetCheck(t *testing.T) {
+	ledger, err := OpenLedger(filepath.Join(t.TempDir(), "webook.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ledger.Close()
+
+	for _, entry := range []LedgerEntry{
+		{Account: "alice", Date: "Feb 18, 2025", Status: LedgerStatusBooked, ReservationID: "r1", CreditsUsed: 2},
+		{Account: "alice", Date: "Feb 19, 2025", Status: LedgerStatusBooked, ReservationID: "r2", CreditsUsed: 1.5},
+		// Refunded
+		{Account: "alice", Date: "Feb 20, 2025", Status: LedgerStatusBooked, ReservationID: "r3", CreditsUsed: 2},
+		{Account: "alice", Date: "Feb 21, 2025", Status: LedgerStatusFailed, CreditsUsed: 2},
+		{Account: "alice", Date: "Mar 3, 2025", Status: LedgerStatusBooked, ReservationID: "r4", CreditsUsed: 2},
+		{Account: "bob", Date: "Feb 18, 2025", Status: LedgerStatusBooked, ReservationID: "r5", CreditsUsed: 2},
+	} {
+		if _, err := ledger.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ledger.MarkCancelled("alice", "r3", "", "Feb 20, 2025"); err != nil {
+		t.Fatal(err)
+	}
+
+	february := time.Date(2025, 2, 24, 0, 0, 0, 0, time.UTC)
+
+	if spent, err := ledger.CreditsSpent("alice", february); err != nil || spent != 3.5 {
+		t.Errorf("Expected 3.5 credits spent in February, but got %v and %v", spent, err)
+	}
+
+	budget := CreditBudget{Monthly: 5, OverBudget: OverBudgetRefuse}
+
+	if err := budget.Check(ledger, "alice", february, 1.5); err != nil {
+		t.Errorf("Expected the booking to fit the budget, but got %v", err)
+	}
+
+	if err := budget.Check(ledger, "alice", february, 2); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded, but got %v", err)
+	}
+
+	budget.OverBudget = OverBudgetWarn
+
+	if err := budget.Check(ledger, "alice", february, 2); err != nil {
+		t.Errorf("Expected only a warning, but got %v", err)
+	}
+
+	if err := (CreditBudget{}).Check(ledger, "alice", february, 100); err != nil {
+		t.Errorf("Expected no budget by default, but got %v", err)
+	}
+
+	if err := (&CreditBudget{Monthly: 10, OverBudget: "ignore"}).validate(); err == nil {
+		t.Errorf("Expected error for an unknown overBudget")
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/eko/gocache/lib/v4/cache"
+	go_cache "github.com/eko/gocache/store/go_cache/v4"
+	gocache "github.com/patrickmn/go-cache"
+)
+
+func newTestBookingDeps(t *testing.T, f *fakeWeWork) (*WeWorkClient, *cache.Cache[[]byte], *Ledger) {
+	client := NewWeWorkClient(weworkAPIURL(f.URL), RetryPolicy{MaxAttempts: 1, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}, CreditBudget{}, false)
+	t.Cleanup(func() { client.Close() })
+
+	ledger, err := OpenLedger(filepath.Join(t.TempDir(), "webook.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { ledger.Close() })
+
+	return client, cache.New[[]byte](go_cache.NewGoCache(gocache.New(time.Hour, time.Minute))), ledger
+}
+
+func TestBookingFlowAgainstFakeWeWork(t *testing.T) {
+	f := newFakeWeWork(t)
+	client, cacheManager, ledger := newTestBookingDeps(t, f)
+	ctx := context.Background()
+
+	date := time.Now().UTC().AddDate(0, 0, 1).Format("Jan 2, 2006")
+	locationID := f.Space.Location.UUID
+
+	results, err := bookDates(ctx, client, f.AccessToken, "default", locationID, []string{date, date}, TimeSlot{}, SpaceFilter{}, cacheManager, ledger)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Status != BookingResultBooked || results[0].ReservationID != "reservation-1" || results[0].CreditsUsed != 2 {
+		t.Errorf("Expected the date to be booked, but got %+v", results[0])
+	}
+
+	if results[1].Status != BookingResultAlreadyBooked || results[1].ReservationID != "reservation-1" {
+		t.Errorf("Expected the second booking to find the first one, but got %+v", results[1])
+	}
+
+	// A morning the day after, priced by the half hour
+	if err := json.Unmarshal([]byte(`{"halfHourCreditPrices": [{"offset": 8, "amount": 1}]}`), &f.Space.ProductPrice); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheManager.Clear(ctx)
+
+	morning := time.Now().UTC().AddDate(0, 0, 2)
+
+	results, err = bookDates(ctx, client, f.AccessToken, "default", locationID, []string{morning.Format("Jan 2, 2006")}, TimeSlot{Start: 9 * 60, End: 13 * 60}, SpaceFilter{}, cacheManager, ledger)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := results[0]; result.Status != BookingResultBooked || result.Start != "09:00" || result.End != "13:00" || result.CreditsUsed != 1 {
+		t.Errorf("Expected the morning to be booked for 1 credit, but got %+v", result)
+	}
+
+	if bookings := f.Bookings(); len(bookings) != 2 || bookings[1].StartTime.Hour() != 9 || bookings[1].EndTime.Hour() != 13 {
+		t.Errorf("Expected the morning to be sent to WeWork, but got %+v", bookings)
+	}
+
+	booking, credits, err := cancelBooking(ctx, client, f.AccessToken, "default", locationID, date, "", ledger)
+
+	if err != nil || booking.ReservationID != "reservation-1" || credits != 2 {
+		t.Errorf("Expected the booking to be cancelled, but got %+v %v %v", booking, credits, err)
+	}
+
+	if bookings := f.Bookings(); len(bookings) != 1 {
+		t.Errorf("Expected the morning booking left, but got %+v", bookings)
+	}
+
+	// Over budget, WeWork is not asked
+	client.budget = CreditBudget{Monthly: 0.5, OverBudget: OverBudgetRefuse}
+
+	results, err = bookDates(ctx, client, f.AccessToken, "default", locationID, []string{time.Now().UTC().AddDate(0, 0, 3).Format("Jan 2, 2006")}, TimeSlot{}, SpaceFilter{}, cacheManager, ledger)
+
+	if err != nil || !errors.Is(results[0].err, ErrBudgetExceeded) || len(f.Bookings()) != 1 {
+		t.Errorf("Expected the booking to be refused over budget, but got %+v and %v", results, err)
+	}
+
+	if _, err := bookDates(ctx, client, "expired", "default", locationID, []string{date}, TimeSlot{}, SpaceFilter{}, cacheManager, ledger); !errors.Is(err, ErrTokenRejected) {
+		t.Errorf("Expected ErrTokenRejected, but got %v", err)
+	}
+}
+
+func TestBookingRetryChecksPreviousAttempt(t *testing.T) {
+	f := newFakeWeWork(t)
+	_, cacheManager, ledger := newTestBookingDeps(t, f)
+	ctx := context.Background()
+
+	client := NewWeWorkClient(weworkAPIURL(f.URL), RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}, CreditBudget{}, false)
+	t.Cleanup(func() { client.Close() })
+
+	locationID := f.Space.Location.UUID
+	date := time.Now().UTC().AddDate(0, 0, 1).Format("Jan 2, 2006")
+
+	// The failed request booked the desk, the retry finds it instead of booking again
+	f.FailBookings = 1
+	f.BookBeforeFailing = true
+
+	results, err := bookDates(ctx, client, f.AccessToken, "default", locationID, []string{date}, TimeSlot{}, SpaceFilter{}, cacheManager, ledger)
+
+	if err != nil || results[0].Status != BookingResultBooked || f.BookingRequests() != 1 || len(f.Bookings()) != 1 {
+		t.Errorf("Expected the desk booked by the failed request, but got %+v and %v", results, err)
+	}
+
+	// WeWork cannot tell whether the failed request booked the desk, it is not sent again
+	f.FailBookings = 1
+	f.BookBeforeFailing = false
+	f.FailUpcoming = true
+
+	results, err = bookDates(ctx, client, f.AccessToken, "default", locationID, []string{time.Now().UTC().AddDate(0, 0, 2).Format("Jan 2, 2006")}, TimeSlot{}, SpaceFilter{}, cacheManager, ledger)
+
+	if err != nil || results[0].Status != BookingResultFailed || f.BookingRequests() != 2 {
+		t.Errorf("Expected a single booking request, but got %+v, %v and %d requests", results, err, f.BookingRequests())
+	}
+}
+
+// findChrome returns the Chrome binary for the end-to-end tests, WEBOOK_CHROME_PATH takes precedence
+func findChrome() string {
+	if path := os.Getenv("WEBOOK_CHROME_PATH"); path != "" {
+		return path
+	}
+
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "headless-shell"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+func TestEndToEndBooking(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the end-to-end test in short mode")
+	}
+
+	chromePath := findChrome()
+
+	if chromePath == "" {
+		t.Skip("Chrome is not installed, set WEBOOK_CHROME_PATH to run the end-to-end test")
+	}
+
+	f := newFakeWeWork(t)
+	client, _, _ := newTestBookingDeps(t, f)
+
+	browser := BrowserConfig{Headless: true, ExecPath: chromePath}
+
+	t.Run("wrong password", func(t *testing.T) {
+		allocCtx, cancel := browser.NewAllocator(t.TempDir())
+		defer cancel()
+
+		if _, _, err := openSession(allocCtx, f.URL, client.retry, f.Email, "wrong"); !errors.Is(err, ErrLoginFailed) {
+			t.Errorf("Expected ErrLoginFailed, but got %v", err)
+		}
+	})
+
+	t.Run("login and book", func(t *testing.T) {
+		allocCtx, cancel := browser.NewAllocator(t.TempDir())
+		defer cancel()
+
+		taskCtx, cancelSession, err := openSession(allocCtx, f.URL, client.retry, f.Email, f.Password)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer cancelSession()
+		defer chromedp.Cancel(taskCtx)
+
+		token, err := getBearerToken(taskCtx)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != f.AccessToken {
+			t.Fatalf("Expected the token seeded by the fake, but got %q", token)
+		}
+
+		start, end, err := bookingSlot(time.Now().UTC().AddDate(0, 0, 1), f.Space, TimeSlot{})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request := newBookingRequest(start, end, f.Space, float64(f.Space.Credits))
+
+		response, err := client.CreateBooking(context.Background(), token, request)
+
+		if err != nil || response.ReservationID == "" {
+			t.Errorf("Expected a reservation, but got %+v and %v", response, err)
+		}
+	})
+}
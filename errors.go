@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Errors of the login, token and booking layers. They are checked with errors.Is and
+// errors.As to answer with the right status, and to decide whether a retry can help
+
+var ErrLoginFailed = errors.New("could not log in to WeWork")
+var ErrMFARequired = errors.New("WeWork asks for a verification code, log in once with the browser to trust this device")
+var ErrPageTimeout = errors.New("timed out waiting for page to load")
+
+var ErrTokenNotFound = errors.New("could not find bearer token")
+var ErrTokenRejected = errors.New("WeWork rejected the access token")
+var ErrRefreshTokenRejected = errors.New("Auth0 rejected the refresh token")
+
+var ErrDateInOlderThanOneMonthFuture = errors.New("date is more than 31 days in the future")
+var ErrBookingNotFound = errors.New("no matching booking found")
+var ErrAlreadyBooked = errors.New("date is already booked")
+var ErrNoSeatsAvailable = errors.New("no seats available")
+var ErrLocationClosed = errors.New("location is closed")
+var ErrInvalidTimeSlot = errors.New("invalid time slot")
+var ErrInvalidSpace = errors.New("invalid space")
+var ErrSpaceNotFound = errors.New("no matching space")
+var ErrBudgetExceeded = errors.New("monthly credit budget exceeded")
+
+// The longest part of a WeWork error body kept in errors and logs
+const maxErrorBodySize = 512
+
+// ErrWeWorkHTTP is an error status from the WeWork API
+type ErrWeWorkHTTP struct {
+	Action string
+	Status int
+	Body   string
+	// From the Retry-After header
+	RetryAfter time.Duration
+}
+
+func (e *ErrWeWorkHTTP) Error() string {
+	message := fmt.Sprintf("WeWork API error %s: %d %s", e.Action, e.Status, http.StatusText(e.Status))
+
+	if e.Body != "" {
+		message += ": " + e.Body
+	}
+
+	return message
+}
+
+// Temporary is true when the same request may succeed later
+func (e *ErrWeWorkHTTP) Temporary() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= http.StatusInternalServerError
+}
+
+// ErrBookingRejected is a booking WeWork answered to without confirming it
+type ErrBookingRejected struct {
+	Errors []string
+}
+
+func (e *ErrBookingRejected) Error() string {
+	return fmt.Sprintf("booking not confirmed: %v", e.Errors)
+}
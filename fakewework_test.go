@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const fakeSessionCookie = "fake_wework_session"
+
+// fakeWeWork stands in for WeWork and its Auth0 login, so the whole booking flow can
+// run offline. The login pages are on their own server, like Auth0 is on its own domain
+type fakeWeWork struct {
+	URL         string
+	AuthURL     string
+	Email       string
+	Password    string
+	ClientID    string
+	AccessToken string
+	Space       WeWorkLocation
+	// Other spaces of the same location
+	Spaces []WeWorkLocation
+	// The next booking requests answer 503, after booking the space when BookBeforeFailing
+	FailBookings      int
+	BookBeforeFailing bool
+	// The upcoming bookings answer 503
+	FailUpcoming bool
+
+	mu              sync.Mutex
+	bookings        []WeWorkBooking
+	bookingRequests int
+}
+
+func newFakeWeWork(t *testing.T) *fakeWeWork {
+	f := &fakeWeWork{
+		Email:       "member@example.com",
+		Password:    "hunter2",
+		ClientID:    "fake-client",
+		AccessToken: fakeJWT(time.Now().Add(time.Hour)),
+	}
+
+	f.Space.UUID = "space-uuid"
+	f.Space.Credits = 2
+	f.Space.Reservable.KubeID = "kube-id"
+	f.Space.Location.UUID = "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+	f.Space.Location.Name = "115 Broadway"
+	f.Space.Location.Address.Line1 = "115 Broadway"
+	f.Space.Location.Address.City = "New York"
+	f.Space.Location.TimeZoneIdentifier = "UTC"
+
+	auth := http.NewServeMux()
+	auth.HandleFunc("GET /u/login", f.handleUsernamePage)
+	auth.HandleFunc("POST /u/login", f.handleUsername)
+	auth.HandleFunc("POST /u/login/password", f.handlePassword)
+
+	authServer := httptest.NewServer(auth)
+	t.Cleanup(authServer.Close)
+	f.AuthURL = authServer.URL
+
+	wework := http.NewServeMux()
+	wework.HandleFunc("GET /workplaceone/content2/wework-support", f.handleSupportPage)
+	wework.HandleFunc("GET /callback", f.handleCallback)
+	wework.HandleFunc("GET /workplaceone/api/spaces/get-spaces", f.requireToken(f.handleGetSpaces))
+	wework.HandleFunc("GET /workplaceone/api/common-booking/upcoming-bookings", f.requireToken(f.handleUpcomingBookings))
+	wework.HandleFunc("POST /workplaceone/api/common-booking/", f.requireToken(f.handleBooking))
+	wework.HandleFunc("POST /workplaceone/api/common-booking/cancel", f.requireToken(f.handleCancel))
+
+	weworkServer := httptest.NewServer(wework)
+	t.Cleanup(weworkServer.Close)
+	f.URL = weworkServer.URL
+
+	return f
+}
+
+func (f *fakeWeWork) Bookings() []WeWorkBooking {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]WeWorkBooking(nil), f.bookings...)
+}
+
+func writePage(w http.ResponseWriter, head string, body string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html><html><head>%s</head><body>%s</body></html>", head, body)
+}
+
+// handleSupportPage shows the log in button, or seeds the localStorage like the Auth0 SPA SDK once logged in
+func (f *fakeWeWork) handleSupportPage(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(fakeSessionCookie); err != nil {
+		writePage(w, "", fmt.Sprintf(`<button onclick="location.href='%s/u/login'">Member log in</button>`, f.AuthURL))
+		return
+	}
+
+	scope := "openid profile email offline_access"
+
+	config, _ := json.Marshal(map[string]any{
+		"clientId":            f.ClientID,
+		"domain":              strings.TrimPrefix(f.AuthURL, "http://"),
+		"authorizationParams": map[string]string{"scope": scope},
+	})
+
+	tokens, _ := json.Marshal(map[string]any{
+		"body": map[string]string{"access_token": f.AccessToken, "refresh_token": "fake-refresh-token"},
+	})
+
+	script := fmt.Sprintf(`<script>
+		localStorage.setItem('Auth0Config', %q);
+		localStorage.setItem('@@auth0spajs@@::%s::wework::openid %s', %q);
+	</script>`, config, f.ClientID, scope, tokens)
+
+	writePage(w, script, `<wework-ondemand-support>Support</wework-ondemand-support>`)
+}
+
+func (f *fakeWeWork) handleCallback(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: fakeSessionCookie, Value: "1", Path: "/"})
+	http.Redirect(w, r, "/workplaceone/content2/wework-support", http.StatusFound)
+}
+
+func (f *fakeWeWork) handleUsernamePage(w http.ResponseWriter, r *http.Request) {
+	writePage(w, "", `<form method="post" action="/u/login">
+		<input id="username" name="username" value="">
+		<button type="submit">Continue</button>
+	</form>`)
+}
+
+func passwordForm(username string, failed bool) string {
+	form := fmt.Sprintf(`<form method="post" action="/u/login/password">
+		<input name="username" value="%s" readonly>
+		<input id="password" name="password" type="password">
+		<button type="submit">Continue</button>
+	</form>`, html.EscapeString(username))
+
+	if failed {
+		form += `<span id="error-element-password">Wrong email or password</span>`
+	}
+
+	return form
+}
+
+func (f *fakeWeWork) handleUsername(w http.ResponseWriter, r *http.Request) {
+	writePage(w, "", passwordForm(r.FormValue("username"), false))
+}
+
+func (f *fakeWeWork) handlePassword(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("username") != f.Email || r.FormValue("password") != f.Password {
+		writePage(w, "", passwordForm(r.FormValue("username"), true))
+		return
+	}
+
+	http.Redirect(w, r, f.URL+"/callback", http.StatusFound)
+}
+
+func (f *fakeWeWork) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+f.AccessToken {
+			http.Error(w, `{"message":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (f *fakeWeWork) handleGetSpaces(w http.ResponseWriter, r *http.Request) {
+	var response WeWorkLocationsResponse
+
+	ids := r.URL.Query().Get("locationUUIDs")
+	search := r.URL.Query().Get("searchText")
+
+	if (ids == "" || strings.Contains(ids, f.Space.Location.UUID)) && (search == "" || f.Space.Matches(search)) {
+		response.GetSharedWorkspaces.Workspaces = append([]WeWorkLocation{f.Space}, f.Spaces...)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (f *fakeWeWork) BookingRequests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.bookingRequests
+}
+
+func (f *fakeWeWork) handleUpcomingBookings(w http.ResponseWriter, r *http.Request) {
+	if f.FailUpcoming {
+		http.Error(w, `{"message":"Service Unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, WeWorkBookingsResponse{Bookings: f.Bookings()})
+}
+
+func (f *fakeWeWork) handleBooking(w http.ResponseWriter, r *http.Request) {
+	var request BookingRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	start, startErr := time.Parse(time.RFC3339, request.StartTime)
+	end, endErr := time.Parse(time.RFC3339, request.EndTime)
+	credits, creditsErr := strconv.ParseFloat(request.MailData.CreditsUsed, 64)
+
+	space, found := f.space(request.WeWorkSpaceID)
+
+	if !found || startErr != nil || endErr != nil || creditsErr != nil {
+		writeJSON(w, http.StatusOK, BookingResponse{BookingStatus: "BookingFailed", Errors: []string{"Space not found"}})
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.bookingRequests++
+	failing := f.FailBookings > 0
+
+	if failing {
+		f.FailBookings--
+	}
+
+	booking := WeWorkBooking{
+		ReservationID: fmt.Sprintf("reservation-%d", len(f.bookings)+1),
+		WeworkUUID:    space.UUID,
+		SpaceType:     request.SpaceType,
+		LocationID:    request.LocationID,
+		SpaceID:       request.SpaceID,
+		StartTime:     start,
+		EndTime:       end,
+		TimezoneIana:  f.Space.Location.TimeZoneIdentifier,
+		CreditsUsed:   credits,
+	}
+
+	if failing && !f.BookBeforeFailing {
+		http.Error(w, `{"message":"Service Unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	f.bookings = append(f.bookings, booking)
+
+	if failing {
+		http.Error(w, `{"message":"Service Unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BookingResponse{
+		BookingStatus: "BookingSuccess",
+		ReservationID: booking.ReservationID,
+		WeworkUUID:    booking.WeworkUUID,
+	})
+}
+
+func (f *fakeWeWork) space(uuid string) (WeWorkLocation, bool) {
+	for _, space := range append([]WeWorkLocation{f.Space}, f.Spaces...) {
+		if space.UUID == uuid {
+			return space, true
+		}
+	}
+
+	return WeWorkLocation{}, false
+}
+
+func (f *fakeWeWork) handleCancel(w http.ResponseWriter, r *http.Request) {
+	var request CancelBookingRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, booking := range f.bookings {
+		if booking.ReservationID == request.ReservationID {
+			f.bookings = append(f.bookings[:i], f.bookings[i+1:]...)
+
+			writeJSON(w, http.StatusOK, CancelBookingResponse{CancellationStatus: "CancellationSuccess", CreditsRefunded: booking.CreditsUsed})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, CancelBookingResponse{CancellationStatus: "CancellationFailed", Errors: []string{"Reservation not found"}})
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const LedgerStatusBooked = "booked"
+const LedgerStatusFailed = "failed"
+const LedgerStatusCancelled = "cancelled"
+const LedgerStatusAlreadyBooked = "already_booked"
+
+var ledgerBucket = []byte("bookings")
+var credentialsBucket = []byte("credentials")
+
+// LedgerEntry is a single booking attempt made by the bot
+type LedgerEntry struct {
+	ID            uint64     `json:"id"`
+	Account       string     `json:"account"`
+	Date          string     `json:"date"`
+	LocationID    string     `json:"locationId"`
+	Status        string     `json:"status"`
+	Error         string     `json:"error,omitempty"`
+	ReservationID string     `json:"reservationId,omitempty"`
+	WeworkUUID    string     `json:"weWorkUuid,omitempty"`
+	CreditsUsed   float64    `json:"creditsUsed,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	CancelledAt   *time.Time `json:"cancelledAt,omitempty"`
+}
+
+// Ledger persists every booking attempt, as well as the recurring schedules and the
+// encrypted credentials, in a local bbolt file
+type Ledger struct {
+	db *bolt.DB
+}
+
+func OpenLedger(path string) (*Ledger, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{ledgerBucket, schedulesBucket, credentialsBucket, waitlistBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Ledger{db: db}, nil
+}
+
+func (l *Ledger) Close() error {
+	return l.db.Close()
+}
+
+// Record stores a new entry and returns it with its ID and timestamps set
+func (l *Ledger) Record(entry LedgerEntry) (LedgerEntry, error) {
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ledgerBucket)
+
+		id, err := bucket.NextSequence()
+
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		entry.ID = id
+		entry.CreatedAt = now
+		entry.UpdatedAt = now
+
+		return putLedgerEntry(bucket, entry)
+	})
+
+	return entry, err
+}
+
+// MarkCancelled flags the entries holding the reservation as cancelled, recording
+// a new entry when the booking was not made by the bot
+func (l *Ledger) MarkCancelled(account string, reservationID string, locationID string, date string) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ledgerBucket)
+		now := time.Now().UTC()
+
+		var entries []LedgerEntry
+
+		if err := bucket.ForEach(func(k, v []byte) error {
+			var entry LedgerEntry
+
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+
+			if entry.ReservationID == reservationID {
+				entries = append(entries, entry)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			id, err := bucket.NextSequence()
+
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, LedgerEntry{
+				ID:            id,
+				Account:       account,
+				Date:          date,
+				LocationID:    locationID,
+				ReservationID: reservationID,
+				CreatedAt:     now,
+			})
+		}
+
+		for _, entry := range entries {
+			entry.Status = LedgerStatusCancelled
+			entry.UpdatedAt = now
+			entry.CancelledAt = &now
+
+			if err := putLedgerEntry(bucket, entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// FindBooked returns the latest successful booking of the account for the location and date that was not cancelled
+func (l *Ledger) FindBooked(account string, locationID string, date string) (LedgerEntry, bool, error) {
+	var entry LedgerEntry
+	var found bool
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		entry, found, err = findLedgerEntry(tx.Bucket(ledgerBucket), func(entry LedgerEntry) bool {
+			return ownerName(entry.Account) == account && entry.Status == LedgerStatusBooked && strings.EqualFold(entry.LocationID, locationID) && entry.Date == date
+		})
+
+		return err
+	})
+
+	return entry, found, err
+}
+
+// DateEntries returns the entries of the account for the location and date, oldest first
+func (l *Ledger) DateEntries(account string, locationID string, date string) ([]LedgerEntry, error) {
+	entries, err := l.Entries(account)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(entries, func(entry LedgerEntry) bool {
+		return !strings.EqualFold(entry.LocationID, locationID) || entry.Date != date
+	}), nil
+}
+
+// Entries returns all the entries of the account, oldest first
+func (l *Ledger) Entries(account string) ([]LedgerEntry, error) {
+	entries := []LedgerEntry{}
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(ledgerBucket).ForEach(func(k, v []byte) error {
+			var entry LedgerEntry
+
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+
+			if ownerName(entry.Account) == account {
+				entries = append(entries, entry)
+			}
+
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// CreditsSpent sums the credits of the bookings the bot made for the account in the
+// month of the given date, the cancelled ones were refunded
+func (l *Ledger) CreditsSpent(account string, month time.Time) (float64, error) {
+	entries, err := l.Entries(account)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var spent float64
+
+	for _, entry := range entries {
+		date, err := time.Parse("Jan 2, 2006", entry.Date)
+
+		if err != nil || entry.Status != LedgerStatusBooked {
+			continue
+		}
+
+		if date.Year() == month.Year() && date.Month() == month.Month() {
+			spent += entry.CreditsUsed
+		}
+	}
+
+	return spent, nil
+}
+
+// Credentials returns the sealed credentials of the account, nil when there are none
+func (l *Ledger) Credentials(account string) ([]byte, error) {
+	var sealed []byte
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		// The value is only valid during the transaction
+		if data := tx.Bucket(credentialsBucket).Get([]byte(account)); data != nil {
+			sealed = append([]byte{}, data...)
+		}
+
+		return nil
+	})
+
+	return sealed, err
+}
+
+func (l *Ledger) SaveCredentials(account string, sealed []byte) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(credentialsBucket).Put([]byte(account), sealed)
+	})
+}
+
+func (l *Ledger) DeleteCredentials(account string) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(credentialsBucket).Delete([]byte(account))
+	})
+}
+
+func findLedgerEntry(bucket *bolt.Bucket, match func(entry LedgerEntry) bool) (LedgerEntry, bool, error) {
+	cursor := bucket.Cursor()
+
+	// Walk backwards so the most recent entry wins
+	for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+		var entry LedgerEntry
+
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return LedgerEntry{}, false, err
+		}
+
+		if match(entry) {
+			return entry, true, nil
+		}
+	}
+
+	return LedgerEntry{}, false, nil
+}
+
+func putLedgerEntry(bucket *bolt.Bucket, entry LedgerEntry) error {
+	data, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, entry.ID)
+
+	return bucket.Put(key, data)
+}
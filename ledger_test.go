@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLedgerMarkCancelled(t *testing.T) {
+	ledger, err := OpenLedger(filepath.Join(t.TempDir(), "webook.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ledger.Close()
+
+	if _, err := ledger.Record(LedgerEntry{Account: "alice", Date: "Feb 18, 2025", LocationID: "loc-a", Status: LedgerStatusBooked, ReservationID: "r1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ledger.MarkCancelled("alice", "r1", "loc-a", "Feb 18, 2025"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bookings made outside of the bot get their own entry
+	if err := ledger.MarkCancelled("alice", "r2", "loc-a", "Feb 19, 2025"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ledger.Entries("alice")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, but got %d", len(entries))
+	}
+
+	for _, entry := range entries {
+		if entry.Status != LedgerStatusCancelled || entry.CancelledAt == nil {
+			t.Errorf("Expected entry %d to be cancelled, but got %s", entry.ID, entry.Status)
+		}
+	}
+
+	if entries[1].ReservationID != "r2" || entries[1].Date != "Feb 19, 2025" {
+		t.Errorf("Unexpected entry for external booking: %+v", entries[1])
+	}
+}
+
+func TestLedgerFindBooked(t *testing.T) {
+	ledger, err := OpenLedger(filepath.Join(t.TempDir(), "webook.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ledger.Close()
+
+	for _, entry := range []LedgerEntry{
+		{Account: "alice", Date: "Feb 18, 2025", LocationID: "loc-a", Status: LedgerStatusFailed},
+		{Account: "alice", Date: "Feb 18, 2025", LocationID: "loc-a", Status: LedgerStatusBooked, ReservationID: "r1"},
+		{Account: "alice", Date: "Feb 19, 2025", LocationID: "loc-a", Status: LedgerStatusBooked, ReservationID: "r2"},
+		{Account: "bob", Date: "Feb 20, 2025", LocationID: "loc-a", Status: LedgerStatusBooked, ReservationID: "r3"},
+		// Entries recorded before accounts existed belong to the default account
+		{Date: "Feb 21, 2025", LocationID: "loc-a", Status: LedgerStatusBooked, ReservationID: "r4"},
+	} {
+		if _, err := ledger.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ledger.MarkCancelled("alice", "r2", "loc-a", "Feb 19, 2025"); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, found, _ := ledger.FindBooked("alice", "loc-a", "Feb 18, 2025"); !found || entry.ReservationID != "r1" {
+		t.Errorf("Expected to find reservation r1, but got %v %+v", found, entry)
+	}
+
+	if _, found, _ := ledger.FindBooked("alice", "loc-a", "Feb 19, 2025"); found {
+		t.Errorf("Did not expect to find a cancelled booking")
+	}
+
+	if _, found, _ := ledger.FindBooked("alice", "loc-b", "Feb 18, 2025"); found {
+		t.Errorf("Did not expect to find a booking at another location")
+	}
+
+	if _, found, _ := ledger.FindBooked("alice", "loc-a", "Feb 20, 2025"); found {
+		t.Errorf("Did not expect to find a booking of another account")
+	}
+
+	if entry, found, _ := ledger.FindBooked(DefaultAccountName, "loc-a", "Feb 21, 2025"); !found || entry.ReservationID != "r4" {
+		t.Errorf("Expected to find reservation r4 for the default account, but got %v %+v", found, entry)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrUnknownLocation = errors.New("unknown location")
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Locations resolves the location given in a request to a WeWork location ID
+type Locations struct {
+	DefaultID string
+	Aliases   map[string]string
+}
+
+// Resolve accepts a location UUID or a configured alias, an empty location returns the default one
+func (l Locations) Resolve(location string) (string, error) {
+	location = strings.TrimSpace(location)
+
+	if location == "" {
+		return l.DefaultID, nil
+	}
+
+	if id, ok := l.Aliases[strings.ToLower(location)]; ok {
+		return id, nil
+	}
+
+	if uuidRegexp.MatchString(location) {
+		return normalizeLocationID(location), nil
+	}
+
+	return "", fmt.Errorf("%w: %q is neither a location ID nor a configured alias", ErrUnknownLocation, location)
+}
+
+// normalizeLocationID lowercases the location ID, so the same location always has the same ledger and cache keys
+func normalizeLocationID(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// parseLocationAliases parses aliases in the format "broadway=<uuid>,soho=<uuid>"
+func parseLocationAliases(aliases string) (map[string]string, error) {
+	result := map[string]string{}
+
+	for _, alias := range strings.Split(aliases, ",") {
+		if strings.TrimSpace(alias) == "" {
+			continue
+		}
+
+		name, id, found := strings.Cut(alias, "=")
+
+		if !found {
+			return nil, fmt.Errorf("invalid location alias %q, expected format: name=<location id>", alias)
+		}
+
+		result[name] = id
+	}
+
+	return normalizeLocationAliases(result)
+}
+
+// normalizeLocationAliases lowercases the aliases and checks they point to a location ID
+func normalizeLocationAliases(aliases map[string]string) (map[string]string, error) {
+	result := map[string]string{}
+
+	for name, id := range aliases {
+		name = strings.ToLower(strings.TrimSpace(name))
+		id = strings.TrimSpace(id)
+
+		if name == "" || !uuidRegexp.MatchString(id) {
+			return nil, fmt.Errorf("invalid location alias %q, expected format: name=<location id>", name+"="+id)
+		}
+
+		result[name] = normalizeLocationID(id)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestLocationsResolve(t *testing.T) {
+	aliases, err := parseLocationAliases("broadway=11111111-2222-3333-4444-555555555555, Soho = AAAAAAAA-BBBB-CCCC-DDDD-EEEEEEEEEEEE,")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations := Locations{DefaultID: "default-id", Aliases: aliases}
+
+	tests := []struct {
+		input    string
+		expected string
+		hasError bool
+	}{
+		{"", "default-id", false},
+		{"broadway", "11111111-2222-3333-4444-555555555555", false},
+		{"SOHO", "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee", false},
+		{"99999999-2222-3333-4444-555555555555", "99999999-2222-3333-4444-555555555555", false},
+		{"chelsea", "", true},
+	}
+
+	for _, test := range tests {
+		result, err := locations.Resolve(test.input)
+
+		if test.hasError {
+			if err == nil {
+				t.Errorf("Expected error for input %s, but got none", test.input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Did not expect error for input %s, but got %v", test.input, err)
+		}
+
+		if result != test.expected {
+			t.Errorf("For input %s, expected %s, but got %s", test.input, test.expected, result)
+		}
+	}
+
+	if _, err := parseLocationAliases("broadway"); err == nil {
+		t.Errorf("Expected error for alias without ID, but got none")
+	}
+}
@@ -6,8 +6,9 @@ import (
 	"net/http"
 	"os"
 	"time"
+	// The bookings are made in the timezone of the location, the image may not have the zoneinfo
+	_ "time/tzdata"
 
-	"github.com/chromedp/chromedp"
 	"github.com/joho/godotenv"
 
 	"github.com/eko/gocache/lib/v4/cache"
@@ -18,29 +19,108 @@ import (
 func main() {
 	godotenv.Load()
 
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.UserDataDir("./chrome-data"),
-		chromedp.Flag("headless", false),
-	)
+	config, err := loadConfig()
 
-	email := os.Getenv("WEWORK_EMAIL")
-	password := os.Getenv("WEWORK_PASSWORD")
-	coworkingLocationID := os.Getenv("WEWORK_COWORKING_LOCATION_ID")
-
-	if email == "" || password == "" || coworkingLocationID == "" {
-		log.Fatal("WEWORK_EMAIL, WEWORK_PASSWORD and WEWORK_COWORKING_LOCATION_ID must be set")
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
+	weworkClient := NewWeWorkClient(weworkAPIURL(config.WeWorkURL), config.Retry, config.Credits, config.Debug)
+	defer weworkClient.Close()
 
 	gocacheClient := gocache.New(7*time.Hour*24, 30*time.Minute)
 	gocacheStore := go_cache.NewGoCache(gocacheClient)
 
 	cacheManager := cache.New[[]byte](gocacheStore)
 
+	ledger, err := OpenLedger(config.LedgerPath)
+
+	if err != nil {
+		log.Fatal("Could not open ledger: ", err)
+	}
+
+	defer ledger.Close()
+
+	credentials, err := NewCredentialStore(ledger, config.SecretKey)
+
+	if err != nil {
+		log.Fatal("Could not set up the credential store: ", err)
+	}
+
+	auth0Client := NewAuth0Client()
+	defer auth0Client.Close()
+
+	accounts := NewAccounts(config, cacheManager, credentials, auth0Client)
+	defer accounts.Close()
+
+	if len(os.Args) > 1 && os.Args[1] == "login" {
+		if err := runLoginCommand(accounts, credentials); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	// The locations command helps finding the location ID, so it does not need one
+	if len(os.Args) > 1 && os.Args[1] == "locations" {
+		if err := runLocationsCommand(accounts.Default(), weworkClient, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	for _, account := range accounts.All() {
+		if account.Locations.DefaultID == "" {
+			log.Fatalf("A location must be set for account %s, with WEWORK_COWORKING_LOCATION_ID or locationId", account.Name)
+		}
+	}
+
+	if !credentials.Persistent() {
+		log.Println("WARNING: WEBOOK_SECRET_KEY is not set, refresh tokens are only kept in memory and the browser is needed after every restart")
+	}
+
+	if err := config.Browser.CheckHealth(context.Background()); err != nil {
+		log.Println("WARNING: remote Chrome is not reachable yet, logins will wait for it:", err)
+	}
+
+	scheduler := NewScheduler(accounts, weworkClient, cacheManager, ledger, config.SchedulerInterval)
+
+	go scheduler.Run(context.Background())
+
+	waitlist := NewWaitlist(accounts, weworkClient, cacheManager, ledger, config.WaitlistInterval, NewNotifier(config.NotifyURL))
+
+	go waitlist.Run(context.Background())
+
+	auth := NewAuthenticator(config.APIKeys)
+
+	if !auth.Enabled() {
+		log.Println("WARNING: no API key configured, anyone reaching the server can book with your credits. Set WEBOOK_API_KEY or apiKeys in WEBOOK_CONFIG")
+	}
+
+	// Left open so health checks do not need a key
+	http.HandleFunc("GET /healthz", registerHealthHandler(config.Browser))
+
 	// also set up a custom logger
-	http.HandleFunc("/api/book", registerBookHandler(allocCtx, email, password, coworkingLocationID, cacheManager))
+	// The original routes keep their plain text responses for existing clients, the v1 ones answer with JSON only
+	for _, prefix := range []string{"/api", "/api/v1"} {
+		http.HandleFunc("POST "+prefix+"/book", auth.Require(ScopeBook, registerBookHandler(accounts, weworkClient, cacheManager, ledger, waitlist)))
+		http.HandleFunc("DELETE "+prefix+"/book", auth.Require(ScopeCancel, registerCancelHandler(accounts, weworkClient, ledger)))
+		http.HandleFunc("GET "+prefix+"/bookings", auth.Require(ScopeRead, registerListBookingsHandler(accounts, weworkClient, cacheManager)))
+		http.HandleFunc("GET "+prefix+"/locations", auth.Require(ScopeRead, registerSearchLocationsHandler(accounts, weworkClient)))
+		http.HandleFunc("GET "+prefix+"/spaces", auth.Require(ScopeRead, registerListSpacesHandler(accounts, weworkClient)))
+		http.HandleFunc("GET "+prefix+"/availability", auth.Require(ScopeRead, registerAvailabilityHandler(accounts, weworkClient)))
+		http.HandleFunc("GET "+prefix+"/waitlist", auth.Require(ScopeRead, registerListWaitlistHandler(accounts, ledger)))
+		http.HandleFunc("DELETE "+prefix+"/waitlist/{id}", auth.Require(ScopeBook, registerDeleteWaitlistHandler(accounts, ledger)))
+		http.HandleFunc("GET "+prefix+"/credits", auth.Require(ScopeRead, registerCreditsHandler(accounts, weworkClient, cacheManager, ledger)))
+		http.HandleFunc("GET "+prefix+"/ledger", auth.Require(ScopeRead, registerLedgerHandler(accounts, ledger)))
+		http.HandleFunc("GET "+prefix+"/schedules", auth.Require(ScopeRead, registerListSchedulesHandler(accounts, ledger)))
+		http.HandleFunc("POST "+prefix+"/schedules", auth.Require(ScopeBook, registerSaveScheduleHandler(accounts, ledger, scheduler)))
+		http.HandleFunc("GET "+prefix+"/schedules/{id}", auth.Require(ScopeRead, registerGetScheduleHandler(accounts, ledger)))
+		http.HandleFunc("PUT "+prefix+"/schedules/{id}", auth.Require(ScopeBook, registerSaveScheduleHandler(accounts, ledger, scheduler)))
+		http.HandleFunc("DELETE "+prefix+"/schedules/{id}", auth.Require(ScopeBook, registerDeleteScheduleHandler(accounts, ledger)))
+	}
+
 	log.Println("Starting server on port 8080...")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Booked when WeWork does not tell the opening hours, in the timezone of the location
+const defaultOpenTime = "06:00"
+const defaultCloseTime = "23:59"
+
+// Layouts of the opening hours returned by WeWork
+var openingHoursLayouts = []string{"15:04", "15:04:05", "3:04 PM", "3:04PM", "3 PM", "3PM"}
+
+// bookingHours returns when the desk is booked on that date: the opening hours of the
+// location for that weekday, in the timezone of the location. The offset is the one of
+// that date, so the bookings stay right across daylight saving time changes
+func bookingHours(date time.Time, space WeWorkLocation) (time.Time, time.Time, error) {
+	location, err := spaceTimezone(space)
+
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	open, close := space.OpenTime, space.CloseTime
+
+	for _, hours := range space.OperatingHours {
+		if !isSameWeekday(hours.Day, hours.DayOfWeek, date.Weekday()) {
+			continue
+		}
+
+		if hours.IsClosed {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: %s is closed on %s", ErrLocationClosed, space.Location.Name, date.Weekday())
+		}
+
+		open, close = hours.Open, hours.Close
+
+		break
+	}
+
+	if open == "" || close == "" {
+		open, close = defaultOpenTime, defaultCloseTime
+	}
+
+	openHour, openMinute, err := parseOpeningHour(open)
+
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	closeHour, closeMinute, err := parseOpeningHour(close)
+
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	start := time.Date(date.Year(), date.Month(), date.Day(), openHour, openMinute, 0, 0, location)
+	end := time.Date(date.Year(), date.Month(), date.Day(), closeHour, closeMinute, 0, 0, location)
+
+	// Locations open until midnight close at 00:00, the booking cannot end the next day
+	if !end.After(start) {
+		end = time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 0, 0, location)
+	}
+
+	return start, end, nil
+}
+
+// spaceTimezone is the timezone of the location, from its IANA name or else its offset
+func spaceTimezone(space WeWorkLocation) (*time.Location, error) {
+	if name := space.Location.TimeZoneIdentifier; name != "" {
+		location, err := time.LoadLocation(name)
+
+		if err == nil {
+			return location, nil
+		}
+	}
+
+	// Without the name the offset is only right for part of the year, but better than none
+	if offset, err := parseUTCOffset(space.Location.TimezoneOffset); err == nil {
+		return time.FixedZone(space.Location.TimezoneOffset, offset), nil
+	}
+
+	return nil, fmt.Errorf("unknown timezone %q for location %s", space.Location.TimeZoneIdentifier, space.Location.Name)
+}
+
+// parseUTCOffset reads offsets like +02:00, -0500 or GMT +08:00, in seconds
+func parseUTCOffset(offset string) (int, error) {
+	offset = strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(offset), "GMT"), " ", "")
+
+	for _, layout := range []string{"-07:00", "-0700", "-07"} {
+		if t, err := time.Parse(layout, offset); err == nil {
+			_, seconds := t.Zone()
+			return seconds, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid UTC offset %q", offset)
+}
+
+func parseOpeningHour(value string) (int, int, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	for _, layout := range openingHoursLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Hour(), t.Minute(), nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("invalid opening hour %q", value)
+}
+
+// isSameWeekday matches the day of the opening hours, by name when there is one as the
+// numbering of dayOfWeek is not documented
+func isSameWeekday(day string, dayOfWeek int, weekday time.Weekday) bool {
+	if day != "" {
+		return strings.EqualFold(strings.TrimSpace(day), weekday.String()) ||
+			strings.EqualFold(strings.TrimSpace(day), weekday.String()[:3])
+	}
+
+	return dayOfWeek == int(weekday)
+}
+
+// formatUTCOffset is the offset of the booking, as WeWork shows it in the confirmation email
+func formatUTCOffset(t time.Time) string {
+	return "GMT " + t.Format("-07:00")
+}
+
+// Partial-day bookings start and end on the half hour, like WeWork prices them
+const slotGranularity = 30
+
+// TimeSlot is the part of the day to book, in minutes after midnight in the timezone of
+// the location. The zero value books the opening hours of the day
+type TimeSlot struct {
+	Start int
+	End   int
+}
+
+// parseTimeSlot reads the start and end of a partial-day booking, e.g. 08:00 and 12:30
+func parseTimeSlot(start string, end string) (TimeSlot, error) {
+	if start == "" && end == "" {
+		return TimeSlot{}, nil
+	}
+
+	if start == "" || end == "" {
+		return TimeSlot{}, fmt.Errorf("%w: both 'start' and 'end' are required", ErrInvalidTimeSlot)
+	}
+
+	var slot TimeSlot
+
+	for _, value := range []struct {
+		text    string
+		minutes *int
+	}{{start, &slot.Start}, {end, &slot.End}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(value.text))
+
+		if err != nil {
+			return TimeSlot{}, fmt.Errorf("%w: %q is not like 08:30", ErrInvalidTimeSlot, value.text)
+		}
+
+		if t.Minute()%slotGranularity != 0 {
+			return TimeSlot{}, fmt.Errorf("%w: %q is not on the half hour", ErrInvalidTimeSlot, value.text)
+		}
+
+		*value.minutes = t.Hour()*60 + t.Minute()
+	}
+
+	if slot.End <= slot.Start {
+		return TimeSlot{}, fmt.Errorf("%w: 'end' must be after 'start'", ErrInvalidTimeSlot)
+	}
+
+	return slot, nil
+}
+
+func (s TimeSlot) IsWholeDay() bool {
+	return s == TimeSlot{}
+}
+
+func (s TimeSlot) String() string {
+	if s.IsWholeDay() {
+		return "whole day"
+	}
+
+	return formatSlotTime(s.Start) + "-" + formatSlotTime(s.End)
+}
+
+func formatSlotTime(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// bookingSlot returns when the desk is booked on that date, the slot has to be within the opening hours
+func bookingSlot(date time.Time, space WeWorkLocation, slot TimeSlot) (time.Time, time.Time, error) {
+	open, close, err := bookingHours(date, space)
+
+	if err != nil || slot.IsWholeDay() {
+		return open, close, err
+	}
+
+	start := time.Date(open.Year(), open.Month(), open.Day(), slot.Start/60, slot.Start%60, 0, 0, open.Location())
+	end := time.Date(open.Year(), open.Month(), open.Day(), slot.End/60, slot.End%60, 0, 0, open.Location())
+
+	if start.Before(open) || end.After(close) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %s is outside the opening hours %s-%s", ErrInvalidTimeSlot, slot, open.Format("15:04"), close.Format("15:04"))
+	}
+
+	return start, end, nil
+}
+
+// bookingCredits is the price of booking from start to end. HalfHourCreditPrices gives
+// the price of a booking by its length in half hours, a booking longer than the longest
+// price, or a location without them, costs the price of the day
+func bookingCredits(space WeWorkLocation, start time.Time, end time.Time, wholeDay bool) float64 {
+	dayPrice := float64(space.Credits)
+
+	if wholeDay {
+		return dayPrice
+	}
+
+	halfHours := int(end.Sub(start) / (slotGranularity * time.Minute))
+	credits, found := dayPrice, false
+
+	for _, price := range space.ProductPrice.HalfHourCreditPrices {
+		// The shortest price covering the booking
+		if price.Offset >= halfHours && (!found || price.Amount < credits) {
+			credits, found = price.Amount, true
+		}
+	}
+
+	if found && dayPrice > 0 {
+		return min(credits, dayPrice)
+	}
+
+	return credits
+}
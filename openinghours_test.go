@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newSpaceWithHours(t *testing.T, timezone string, hours string) WeWorkLocation {
+	var space WeWorkLocation
+
+	data := `{"location": {"name": "Test", "timeZoneIdentifier": "` + timezone + `"}, "operatingHours": ` + hours + `}`
+
+	if err := json.Unmarshal([]byte(data), &space); err != nil {
+		t.Fatal(err)
+	}
+
+	return space
+}
+
+func TestBookingHours(t *testing.T) {
+	weekdays := `[
+		{"dayOfWeek": 0, "day": "Sunday", "isClosed": true},
+		{"dayOfWeek": 1, "day": "Monday", "open": "08:00", "close": "18:00"},
+		{"dayOfWeek": 6, "day": "Saturday", "open": "10:00 AM", "close": "00:00"}
+	]`
+
+	tests := []struct {
+		name     string
+		timezone string
+		date     time.Time
+		start    string
+		end      string
+	}{
+		{"New York in summer", "America/New_York", time.Date(2025, 7, 14, 0, 0, 0, 0, time.UTC), "2025-07-14T12:00:00Z", "2025-07-14T22:00:00Z"},
+		{"New York in winter", "America/New_York", time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), "2025-01-13T13:00:00Z", "2025-01-13T23:00:00Z"},
+		{"London in summer", "Europe/London", time.Date(2025, 7, 14, 0, 0, 0, 0, time.UTC), "2025-07-14T07:00:00Z", "2025-07-14T17:00:00Z"},
+		{"London in winter", "Europe/London", time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), "2025-01-13T08:00:00Z", "2025-01-13T18:00:00Z"},
+		{"Singapore", "Asia/Singapore", time.Date(2025, 7, 14, 0, 0, 0, 0, time.UTC), "2025-07-14T00:00:00Z", "2025-07-14T10:00:00Z"},
+		// Open until midnight, booked until the end of the day
+		{"Saturday in Paris", "Europe/Paris", time.Date(2025, 7, 12, 0, 0, 0, 0, time.UTC), "2025-07-12T08:00:00Z", "2025-07-12T21:59:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end, err := bookingHours(test.date, newSpaceWithHours(t, test.timezone, weekdays))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if start.UTC().Format(time.RFC3339) != test.start || end.UTC().Format(time.RFC3339) != test.end {
+				t.Errorf("Expected %s to %s, but got %s to %s", test.start, test.end, start.UTC(), end.UTC())
+			}
+		})
+	}
+
+	// Clocks go forward at 2am on March 9, 2025 in New York, the opening is already in EDT
+	space := newSpaceWithHours(t, "America/New_York", `[{"day": "Sunday", "open": "06:00", "close": "20:00"}]`)
+
+	if start, end, err := bookingHours(time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC), space); err != nil || start.UTC().Hour() != 10 || end.UTC().Hour() != 0 {
+		t.Errorf("Expected 10:00 to 00:00 UTC on the day of the change, but got %s to %s and %v", start.UTC(), end.UTC(), err)
+	}
+
+	if _, _, err := bookingHours(time.Date(2025, 7, 13, 0, 0, 0, 0, time.UTC), newSpaceWithHours(t, "Europe/London", weekdays)); !errors.Is(err, ErrLocationClosed) {
+		t.Errorf("Expected ErrLocationClosed on Sunday, but got %v", err)
+	}
+
+	// Without opening hours for the day, the previous 06:00 to 23:59 is booked
+	if start, end, err := bookingHours(time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC), newSpaceWithHours(t, "Europe/Paris", weekdays)); err != nil || start.Format("15:04") != "06:00" || end.Format("15:04") != "23:59" {
+		t.Errorf("Expected the default hours, but got %s to %s and %v", start, end, err)
+	}
+
+	// Only the offset is known
+	space = newSpaceWithHours(t, "", `[]`)
+	space.Location.TimezoneOffset = "+08:00"
+
+	if start, _, err := bookingHours(time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC), space); err != nil || start.UTC().Format(time.RFC3339) != "2025-07-14T22:00:00Z" {
+		t.Errorf("Expected the offset to be used, but got %s and %v", start.UTC(), err)
+	}
+
+	if _, _, err := bookingHours(time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC), newSpaceWithHours(t, "Mars/Olympus_Mons", `[]`)); err == nil {
+		t.Errorf("Expected error for an unknown timezone")
+	}
+}
+
+func TestParseTimeSlot(t *testing.T) {
+	if slot, err := parseTimeSlot("", ""); err != nil || !slot.IsWholeDay() {
+		t.Errorf("Expected the whole day, but got %v and %v", slot, err)
+	}
+
+	if slot, err := parseTimeSlot("08:00", "12:30"); err != nil || slot != (TimeSlot{Start: 8 * 60, End: 12*60 + 30}) || slot.String() != "08:00-12:30" {
+		t.Errorf("Expected 08:00-12:30, but got %v and %v", slot, err)
+	}
+
+	for _, invalid := range [][2]string{{"08:00", ""}, {"8am", "12:00"}, {"08:15", "12:00"}, {"12:00", "08:00"}, {"08:00", "08:00"}} {
+		if _, err := parseTimeSlot(invalid[0], invalid[1]); !errors.Is(err, ErrInvalidTimeSlot) {
+			t.Errorf("Expected ErrInvalidTimeSlot for %v, but got %v", invalid, err)
+		}
+	}
+}
+
+func TestBookingSlotAndCredits(t *testing.T) {
+	space := newSpaceWithHours(t, "America/New_York", `[{"day": "Monday", "open": "08:00", "close": "18:00"}]`)
+	space.Credits = 4
+
+	if err := json.Unmarshal([]byte(`{"halfHourCreditPrices": [{"offset": 2, "amount": 0.5}, {"offset": 8, "amount": 2}, {"offset": 12, "amount": 3}]}`), &space.ProductPrice); err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2025, 7, 14, 0, 0, 0, 0, time.UTC)
+
+	start, end, err := bookingSlot(monday, space, TimeSlot{Start: 8 * 60, End: 12 * 60})
+
+	if err != nil || start.UTC().Format(time.RFC3339) != "2025-07-14T12:00:00Z" || end.UTC().Format(time.RFC3339) != "2025-07-14T16:00:00Z" {
+		t.Fatalf("Expected 08:00 to 12:00 in New York, but got %s to %s and %v", start.UTC(), end.UTC(), err)
+	}
+
+	if credits := bookingCredits(space, start, end, false); credits != 2 {
+		t.Errorf("Expected 4 hours to cost 2 credits, but got %v", credits)
+	}
+
+	// Priced as the next longer booking
+	if credits := bookingCredits(space, start, start.Add(5*time.Hour), false); credits != 3 {
+		t.Errorf("Expected 5 hours to cost 3 credits, but got %v", credits)
+	}
+
+	if credits := bookingCredits(space, start, start.Add(8*time.Hour), false); credits != 4 {
+		t.Errorf("Expected 8 hours to cost the day, but got %v", credits)
+	}
+
+	if credits := bookingCredits(space, start, end, true); credits != 4 {
+		t.Errorf("Expected the whole day to cost 4 credits, but got %v", credits)
+	}
+
+	for _, slot := range []TimeSlot{{Start: 7 * 60, End: 12 * 60}, {Start: 17 * 60, End: 19 * 60}} {
+		if _, _, err := bookingSlot(monday, space, slot); !errors.Is(err, ErrInvalidTimeSlot) {
+			t.Errorf("Expected %s to be outside the opening hours, but got %v", slot, err)
+		}
+	}
+}
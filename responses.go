@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// The versioned API answers with JSON only, the original /api routes keep their plain text responses
+const apiV1Prefix = "/api/v1/"
+
+// Error codes of the v1 API, they are part of the contract and must not change
+const ErrorCodeInvalidRequest = "invalid_request"
+const ErrorCodeInvalidDate = "invalid_date"
+const ErrorCodeInvalidTimeSlot = "invalid_time_slot"
+const ErrorCodeInvalidSpace = "invalid_space"
+const ErrorCodeTooFarInFuture = "too_far_in_future"
+const ErrorCodeAlreadyBooked = "already_booked"
+const ErrorCodeUnknownLocation = "unknown_location"
+const ErrorCodeUnauthorized = "unauthorized"
+const ErrorCodeForbidden = "forbidden"
+const ErrorCodeNotFound = "not_found"
+const ErrorCodeSpaceNotFound = "space_not_found"
+const ErrorCodeLoginFailed = "login_failed"
+const ErrorCodeMFARequired = "mfa_required"
+const ErrorCodeBrowserTimeout = "browser_timeout"
+const ErrorCodeTokenMissing = "token_missing"
+const ErrorCodeWeWorkAPI = "wework_api_error"
+const ErrorCodeNoSeats = "no_seats"
+const ErrorCodeLocationClosed = "location_closed"
+const ErrorCodeBudgetExceeded = "budget_exceeded"
+const ErrorCodeBookingRejected = "booking_rejected"
+const ErrorCodeInternal = "internal_error"
+
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// The messages of WeWork, when it rejected the booking
+	Details []string `json:"details,omitempty"`
+	// True when the same request may succeed later, otherwise a human needs to look at it
+	Retryable bool `json:"retryable"`
+}
+
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// APIBooking is the v1 response for a booked or cancelled date
+type APIBooking struct {
+	Status          string    `json:"status"`
+	Date            string    `json:"date,omitempty"`
+	Location        string    `json:"location,omitempty"`
+	ReservationID   string    `json:"reservationId,omitempty"`
+	Start           string    `json:"start,omitempty"`
+	End             string    `json:"end,omitempty"`
+	CreditsUsed     float64   `json:"creditsUsed,omitempty"`
+	CreditsRefunded float64   `json:"creditsRefunded,omitempty"`
+	WaitlistID      uint64    `json:"waitlistId,omitempty"`
+	Error           *APIError `json:"error,omitempty"`
+}
+
+func newAPIBooking(result BookingResult) APIBooking {
+	booking := APIBooking{
+		Status:        result.Status,
+		Date:          result.Date,
+		Location:      result.Location,
+		ReservationID: result.ReservationID,
+		Start:         result.Start,
+		End:           result.End,
+		CreditsUsed:   result.CreditsUsed,
+		WaitlistID:    result.WaitlistID,
+	}
+
+	if result.err != nil && !errors.Is(result.err, ErrAlreadyBooked) {
+		_, apiError := classifyError(result.err, http.StatusInternalServerError)
+		booking.Error = &apiError
+	}
+
+	return booking
+}
+
+// bookingStatusCode is the status of the v1 response when a single date is booked
+func bookingStatusCode(result BookingResult) int {
+	switch {
+	case result.Status == BookingResultAlreadyBooked:
+		return http.StatusConflict
+	case result.Status == BookingResultWaitlisted:
+		return http.StatusAccepted
+	case result.err != nil:
+		status, _ := classifyError(result.err, http.StatusInternalServerError)
+		return status
+	default:
+		return http.StatusOK
+	}
+}
+
+// classifyError returns the status and the v1 error for err, the status is only used
+// when the error is not one of the known ones
+func classifyError(err error, status int) (int, APIError) {
+	apiError := APIError{Message: err.Error()}
+
+	var httpErr *ErrWeWorkHTTP
+	var rejectedErr *ErrBookingRejected
+
+	switch {
+	case errors.Is(err, ErrInvalidDate):
+		status, apiError.Code = http.StatusBadRequest, ErrorCodeInvalidDate
+	case errors.Is(err, ErrInvalidTimeSlot):
+		status, apiError.Code = http.StatusBadRequest, ErrorCodeInvalidTimeSlot
+	case errors.Is(err, ErrInvalidSpace):
+		status, apiError.Code = http.StatusBadRequest, ErrorCodeInvalidSpace
+	case errors.Is(err, ErrDateInOlderThanOneMonthFuture):
+		status, apiError.Code = http.StatusBadRequest, ErrorCodeTooFarInFuture
+	case errors.Is(err, ErrAlreadyBooked):
+		status, apiError.Code = http.StatusConflict, ErrorCodeAlreadyBooked
+	case errors.Is(err, ErrUnknownLocation):
+		status, apiError.Code = http.StatusBadRequest, ErrorCodeUnknownLocation
+	case errors.Is(err, ErrUnauthorized):
+		status, apiError.Code = http.StatusUnauthorized, ErrorCodeUnauthorized
+	case errors.Is(err, ErrForbiddenAccount):
+		status, apiError.Code = http.StatusForbidden, ErrorCodeForbidden
+	case errors.Is(err, ErrSpaceNotFound):
+		status, apiError.Code = http.StatusNotFound, ErrorCodeSpaceNotFound
+	case errors.Is(err, ErrBookingNotFound):
+		status, apiError.Code = http.StatusNotFound, ErrorCodeNotFound
+	case errors.Is(err, ErrMFARequired):
+		status, apiError.Code = http.StatusBadGateway, ErrorCodeMFARequired
+	case errors.Is(err, ErrLoginFailed):
+		status, apiError.Code = http.StatusBadGateway, ErrorCodeLoginFailed
+	case errors.Is(err, ErrPageTimeout):
+		status, apiError.Code, apiError.Retryable = http.StatusGatewayTimeout, ErrorCodeBrowserTimeout, true
+	case errors.Is(err, ErrTokenNotFound), errors.Is(err, ErrTokenRejected):
+		status, apiError.Code, apiError.Retryable = http.StatusBadGateway, ErrorCodeTokenMissing, true
+	case errors.Is(err, ErrNoSeatsAvailable):
+		status, apiError.Code = http.StatusConflict, ErrorCodeNoSeats
+	case errors.Is(err, ErrLocationClosed):
+		status, apiError.Code = http.StatusConflict, ErrorCodeLocationClosed
+	case errors.Is(err, ErrBudgetExceeded):
+		status, apiError.Code = http.StatusPaymentRequired, ErrorCodeBudgetExceeded
+	case errors.As(err, &rejectedErr):
+		status, apiError.Code = http.StatusUnprocessableEntity, ErrorCodeBookingRejected
+		apiError.Details = rejectedErr.Errors
+	case errors.As(err, &httpErr) && httpErr.Temporary():
+		status, apiError.Code, apiError.Retryable = http.StatusServiceUnavailable, ErrorCodeWeWorkAPI, true
+	case errors.As(err, &httpErr):
+		status, apiError.Code = http.StatusBadGateway, ErrorCodeWeWorkAPI
+	default:
+		apiError.Code = errorCodeForStatus(status)
+	}
+
+	return status, apiError
+}
+
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	default:
+		return ErrorCodeInternal
+	}
+}
+
+func isAPIV1(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiV1Prefix)
+}
+
+// writeError answers with a JSON error on the v1 API, and with the plain text error on
+// the original routes. Both get the status of the error when it is a known one
+func writeError(w http.ResponseWriter, r *http.Request, err error, status int) {
+	status, apiError := classifyError(err, status)
+
+	if !isAPIV1(r) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, status, APIErrorResponse{Error: apiError})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(value)
+}
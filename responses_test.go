@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	rejected := &ErrBookingRejected{Errors: []string{"Space is closed"}}
+
+	tests := []struct {
+		err       error
+		status    int
+		code      string
+		retryable bool
+	}{
+		{fmt.Errorf("%w %q", ErrInvalidDate, "tomorrow"), http.StatusBadRequest, ErrorCodeInvalidDate, false},
+		{ErrDateInOlderThanOneMonthFuture, http.StatusBadRequest, ErrorCodeTooFarInFuture, false},
+		{fmt.Errorf("%w: wrong email or password", ErrLoginFailed), http.StatusBadGateway, ErrorCodeLoginFailed, false},
+		{ErrMFARequired, http.StatusBadGateway, ErrorCodeMFARequired, false},
+		{fmt.Errorf("%w: %w", ErrLoginFailed, ErrPageTimeout), http.StatusBadGateway, ErrorCodeLoginFailed, false},
+		{ErrPageTimeout, http.StatusGatewayTimeout, ErrorCodeBrowserTimeout, true},
+		{ErrTokenNotFound, http.StatusBadGateway, ErrorCodeTokenMissing, true},
+		{&ErrWeWorkHTTP{Action: "making booking request", Status: http.StatusInternalServerError}, http.StatusServiceUnavailable, ErrorCodeWeWorkAPI, true},
+		{&ErrWeWorkHTTP{Action: "making booking request", Status: http.StatusBadRequest, Body: "bad"}, http.StatusBadGateway, ErrorCodeWeWorkAPI, false},
+		{fmt.Errorf("%w: [Sold out]", ErrNoSeatsAvailable), http.StatusConflict, ErrorCodeNoSeats, false},
+		{fmt.Errorf("%w: 115 Broadway is closed on Sunday", ErrLocationClosed), http.StatusConflict, ErrorCodeLocationClosed, false},
+		{fmt.Errorf("%w: alice already spent 10 of 10 credits in February 2025, the booking costs 2", ErrBudgetExceeded), http.StatusPaymentRequired, ErrorCodeBudgetExceeded, false},
+		{fmt.Errorf("%w: 07:00-12:00 is outside the opening hours 08:00-18:00", ErrInvalidTimeSlot), http.StatusBadRequest, ErrorCodeInvalidTimeSlot, false},
+		{fmt.Errorf("%w: 'capacity' must not be negative", ErrInvalidSpace), http.StatusBadRequest, ErrorCodeInvalidSpace, false},
+		{fmt.Errorf("%w: no desk for 20 at loc-a", ErrSpaceNotFound), http.StatusNotFound, ErrorCodeSpaceNotFound, false},
+		{fmt.Errorf("booking Feb 18: %w", rejected), http.StatusUnprocessableEntity, ErrorCodeBookingRejected, false},
+		{errors.New("Missing 'query' query parameter"), http.StatusBadRequest, ErrorCodeInvalidRequest, false},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+
+		// Unknown errors keep the status of the handler
+		writeError(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/book", nil), test.err, http.StatusBadRequest)
+
+		var response APIErrorResponse
+
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		if recorder.Code != test.status || response.Error.Code != test.code || response.Error.Message != test.err.Error() || response.Error.Retryable != test.retryable {
+			t.Errorf("Expected %d %s for %v, but got %d %+v", test.status, test.code, test.err, recorder.Code, response.Error)
+		}
+	}
+
+	// The original routes keep their plain text errors, with the same statuses
+	recorder := httptest.NewRecorder()
+
+	writeError(recorder, httptest.NewRequest(http.MethodPost, "/api/book", nil), rejected, http.StatusInternalServerError)
+
+	if recorder.Code != http.StatusUnprocessableEntity || strings.TrimSpace(recorder.Body.String()) != rejected.Error() {
+		t.Errorf("Unexpected legacy response %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestNewAPIBooking(t *testing.T) {
+	booking := newAPIBooking(BookingResult{
+		Date:     "Feb 18, 2025",
+		Status:   BookingResultFailed,
+		Location: "loc-a",
+		Details:  []string{"Space is closed"},
+		err:      &ErrBookingRejected{Errors: []string{"Space is closed"}},
+	})
+
+	if booking.Error == nil || booking.Error.Code != ErrorCodeBookingRejected || len(booking.Error.Details) != 1 {
+		t.Fatalf("Unexpected booking %+v", booking)
+	}
+
+	if status := bookingStatusCode(BookingResult{Status: BookingResultAlreadyBooked, err: ErrAlreadyBooked}); status != http.StatusConflict {
+		t.Errorf("Expected 409 for an existing reservation, but got %d", status)
+	}
+
+	if status := bookingStatusCode(BookingResult{Status: BookingResultFailed, err: ErrNoSeatsAvailable}.waitlisted(WaitlistEntry{ID: 3})); status != http.StatusAccepted {
+		t.Errorf("Expected 202 for a waitlisted date, but got %d", status)
+	}
+
+	if booking := newAPIBooking(BookingResult{Status: BookingResultAlreadyBooked, ReservationID: "r1", err: ErrAlreadyBooked}); booking.Error != nil {
+		t.Errorf("Did not expect an error for an existing reservation, but got %+v", booking.Error)
+	}
+}
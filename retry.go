@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries the calls to WeWork, and the browser navigation, that failed for
+// a reason that may go away, e.g. a 503 or a timeout
+type RetryPolicy struct {
+	MaxAttempts  int           `yaml:"maxAttempts"`
+	InitialDelay time.Duration `yaml:"initialDelay"`
+	MaxDelay     time.Duration `yaml:"maxDelay"`
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 4, InitialDelay: time.Second, MaxDelay: 30 * time.Second}
+}
+
+// applyEnv overrides the policy with the WEBOOK_RETRY_* variables
+func (p *RetryPolicy) applyEnv() error {
+	if attempts := os.Getenv("WEBOOK_RETRY_MAX_ATTEMPTS"); attempts != "" {
+		value, err := strconv.Atoi(attempts)
+
+		if err != nil {
+			return fmt.Errorf("invalid WEBOOK_RETRY_MAX_ATTEMPTS: %w", err)
+		}
+
+		p.MaxAttempts = value
+	}
+
+	for variable, setting := range map[string]*time.Duration{
+		"WEBOOK_RETRY_INITIAL_DELAY": &p.InitialDelay,
+		"WEBOOK_RETRY_MAX_DELAY":     &p.MaxDelay,
+	} {
+		if value := os.Getenv(variable); value != "" {
+			d, err := time.ParseDuration(value)
+
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", variable, err)
+			}
+
+			*setting = d
+		}
+	}
+
+	return nil
+}
+
+// withDefaults fills the settings left empty
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaults.InitialDelay
+	}
+
+	if p.MaxDelay < p.InitialDelay {
+		p.MaxDelay = max(defaults.MaxDelay, p.InitialDelay)
+	}
+
+	return p
+}
+
+// Do calls fn until it succeeds, fails with an error that is not temporary, or the attempts run out
+func (p RetryPolicy) Do(ctx context.Context, action string, fn func(attempt int) error) error {
+	return p.DoIf(ctx, action, isTemporaryError, fn)
+}
+
+// DoIf is Do with the errors worth retrying picked by retryable
+func (p RetryPolicy) DoIf(ctx context.Context, action string, retryable func(error) bool, fn func(attempt int) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+
+		delay := p.delay(attempt)
+
+		// WeWork tells how long to wait when it limits the requests
+		var httpErr *ErrWeWorkHTTP
+
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+			if httpErr.RetryAfter > p.MaxDelay {
+				log.Printf("Not retrying %s, WeWork asked to wait %s: %s", action, httpErr.RetryAfter, err)
+				return err
+			}
+
+			delay = httpErr.RetryAfter
+		}
+
+		log.Printf("Attempt %d/%d of %s failed, retrying in %s: %s", attempt, p.MaxAttempts, action, delay.Round(time.Millisecond), err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// delay is the exponential backoff before the next attempt. The jitter keeps the
+// accounts booking at the same time from retrying all at once
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	delay = min(delay, p.MaxDelay)
+
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// isTemporaryError is true for network errors, timeouts, 429 and 5xx statuses. A
+// request timing out is retried, DoIf already stops once the caller's context is done
+func isTemporaryError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var httpErr *ErrWeWorkHTTP
+
+	if errors.As(err, &httpErr) {
+		return httpErr.Temporary()
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
+
+func isNavigationRetryable(err error) bool {
+	return !errors.Is(err, context.Canceled)
+}
+
+// parseRetryAfter reads the Retry-After header, either a number of seconds or a date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	ctx := context.Background()
+
+	unavailable := &ErrWeWorkHTTP{Action: "fetching bookings", Status: http.StatusServiceUnavailable}
+
+	calls := 0
+
+	err := policy.Do(ctx, "test", func(attempt int) error {
+		calls++
+
+		if attempt < 3 {
+			return unavailable
+		}
+
+		return nil
+	})
+
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third attempt, but got %d calls and %v", calls, err)
+	}
+
+	// The attempts run out
+	calls = 0
+
+	if err := policy.Do(ctx, "test", func(int) error { calls++; return unavailable }); err != unavailable || calls != 3 {
+		t.Errorf("Expected the last error after 3 calls, but got %d calls and %v", calls, err)
+	}
+
+	// Errors that would happen again are not retried
+	calls = 0
+
+	for _, permanent := range []error{
+		&ErrWeWorkHTTP{Action: "making booking request", Status: http.StatusBadRequest},
+		&ErrBookingRejected{Errors: []string{"Space is closed"}},
+		ErrTokenRejected,
+		context.Canceled,
+	} {
+		if err := policy.Do(ctx, "test", func(int) error { calls++; return permanent }); err != permanent {
+			t.Errorf("Expected %v, but got %v", permanent, err)
+		}
+	}
+
+	if calls != 4 {
+		t.Errorf("Expected a single call per permanent error, but got %d", calls)
+	}
+
+	// A Retry-After longer than the policy allows is not waited for
+	calls = 0
+	limited := &ErrWeWorkHTTP{Action: "test", Status: http.StatusTooManyRequests, RetryAfter: time.Hour}
+
+	if err := policy.Do(ctx, "test", func(int) error { calls++; return limited }); err != limited || calls != 1 {
+		t.Errorf("Expected a single call, but got %d calls and %v", calls, err)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 9: 10 * time.Second} {
+		// The jitter keeps the delay between half and the full backoff
+		if delay := policy.delay(attempt); delay < expected/2 || delay > expected {
+			t.Errorf("Expected a delay between %s and %s for attempt %d, but got %s", expected/2, expected, attempt, delay)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.February, 17, 10, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"Mon, 17 Feb 2025 10:00:30 GMT": 30 * time.Second,
+		"Mon, 17 Feb 2025 09:00:00 GMT": 0,
+		"soon":                          0,
+	}
+
+	for value, expected := range tests {
+		if delay := parseRetryAfter(value, now); delay != expected {
+			t.Errorf("Expected %s for %q, but got %s", expected, value, delay)
+		}
+	}
+
+	if !isTemporaryError(&ErrWeWorkHTTP{Status: http.StatusBadGateway}) || isTemporaryError(errors.New("no locations found")) {
+		t.Errorf("Unexpected temporary errors")
+	}
+}
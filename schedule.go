@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eko/gocache/lib/v4/cache"
+	bolt "go.etcd.io/bbolt"
+)
+
+var schedulesBucket = []byte("schedules")
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// Schedule is a recurring booking rule, every matching weekday is booked as soon
+// as it enters the booking window
+type Schedule struct {
+	ID         uint64   `json:"id"`
+	Account    string   `json:"account"`
+	LocationID string   `json:"locationId"`
+	Weekdays   []string `json:"weekdays"`
+	// Part of the day and space, any desk for the opening hours by default
+	Start    string `json:"start,omitempty"`
+	End      string `json:"end,omitempty"`
+	SpaceID  string `json:"spaceId,omitempty"`
+	Capacity int    `json:"capacity,omitempty"`
+	// Dates not to book, e.g. the ones whose booking was cancelled
+	Skip      []string  `json:"skip,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Normalize validates the schedule and rewrites the weekdays to their full lowercase name
+func (s *Schedule) Normalize() error {
+	if _, err := parseTimeSlot(s.Start, s.End); err != nil {
+		return err
+	}
+
+	if _, err := newSpaceFilter(s.SpaceID, s.Capacity); err != nil {
+		return err
+	}
+
+	for i, date := range s.Skip {
+		skipped, err := reformatDate(date)
+
+		if err != nil {
+			return fmt.Errorf("%w %q in 'skip'. Expected format: 'Feb 18, 2025'", ErrInvalidDate, date)
+		}
+
+		s.Skip[i] = skipped
+	}
+
+	if len(s.Weekdays) == 0 {
+		return errors.New("at least one weekday is required")
+	}
+
+	weekdays := make([]string, 0, len(s.Weekdays))
+
+	for _, day := range s.Weekdays {
+		weekday, err := parseWeekday(day)
+
+		if err != nil {
+			return err
+		}
+
+		weekdays = append(weekdays, strings.ToLower(weekday.String()))
+	}
+
+	s.Weekdays = weekdays
+
+	return nil
+}
+
+// location is where the schedule books, the default location of the account when it has none
+func (s Schedule) location(account *Account) string {
+	if s.LocationID == "" {
+		return account.Locations.DefaultID
+	}
+
+	return s.LocationID
+}
+
+// booking is what every date of the schedule books, the schedule was validated when saved
+func (s Schedule) booking(locationID string) scheduledBooking {
+	slot, _ := parseTimeSlot(s.Start, s.End)
+	space, _ := newSpaceFilter(s.SpaceID, s.Capacity)
+
+	return scheduledBooking{locationID: locationID, slot: slot, space: space}
+}
+
+// scheduledBooking groups the dates of the schedules booking the same thing
+type scheduledBooking struct {
+	locationID string
+	slot       TimeSlot
+	space      SpaceFilter
+}
+
+// BelongsTo checks the schedule is owned by the account
+func (s Schedule) BelongsTo(account *Account) bool {
+	return ownerName(s.Account) == account.Name
+}
+
+// ownerName maps what was stored before multiple accounts were supported to the default account
+func ownerName(account string) string {
+	if account == "" {
+		return DefaultAccountName
+	}
+
+	return account
+}
+
+// datesToBook returns every date matching the schedule that is currently within the booking window
+func (s Schedule) datesToBook(now time.Time) []time.Time {
+	weekdays := map[time.Weekday]bool{}
+
+	for _, day := range s.Weekdays {
+		if weekday, err := parseWeekday(day); err == nil {
+			weekdays[weekday] = true
+		}
+	}
+
+	var dates []time.Time
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	for d := today; isWithinBookingWindow(d, now); d = d.AddDate(0, 0, 1) {
+		if weekdays[d.Weekday()] && !slices.Contains(s.Skip, d.Format("Jan 2, 2006")) {
+			dates = append(dates, d)
+		}
+	}
+
+	return dates
+}
+
+// isWithinBookingWindow mirrors WeWork only accepting bookings up to 31 days ahead
+func isWithinBookingWindow(d time.Time, now time.Time) bool {
+	return d.Sub(now) <= 31*24*time.Hour
+}
+
+func parseWeekday(day string) (time.Weekday, error) {
+	day = strings.ToLower(strings.TrimSpace(day))
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+
+		if day == name || (len(day) >= 3 && strings.HasPrefix(name, day)) {
+			return weekday, nil
+		}
+	}
+
+	return time.Sunday, fmt.Errorf("invalid weekday: %q", day)
+}
+
+// SaveSchedule creates the schedule when it has no ID, otherwise replaces the existing one
+func (l *Ledger) SaveSchedule(schedule Schedule) (Schedule, error) {
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(schedulesBucket)
+		now := time.Now().UTC()
+
+		if schedule.ID == 0 {
+			id, err := bucket.NextSequence()
+
+			if err != nil {
+				return err
+			}
+
+			schedule.ID = id
+			schedule.CreatedAt = now
+		} else {
+			existing, err := getSchedule(bucket, schedule.ID)
+
+			if err != nil {
+				return err
+			}
+
+			schedule.CreatedAt = existing.CreatedAt
+		}
+
+		schedule.UpdatedAt = now
+
+		data, err := json.Marshal(schedule)
+
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(scheduleKey(schedule.ID), data)
+	})
+
+	return schedule, err
+}
+
+func (l *Ledger) Schedule(id uint64) (Schedule, error) {
+	var schedule Schedule
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		schedule, err = getSchedule(tx.Bucket(schedulesBucket), id)
+
+		return err
+	})
+
+	return schedule, err
+}
+
+func (l *Ledger) Schedules() ([]Schedule, error) {
+	schedules := []Schedule{}
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).ForEach(func(k, v []byte) error {
+			var schedule Schedule
+
+			if err := json.Unmarshal(v, &schedule); err != nil {
+				return err
+			}
+
+			schedules = append(schedules, schedule)
+
+			return nil
+		})
+	})
+
+	return schedules, err
+}
+
+func (l *Ledger) DeleteSchedule(id uint64) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(schedulesBucket)
+
+		if _, err := getSchedule(bucket, id); err != nil {
+			return err
+		}
+
+		return bucket.Delete(scheduleKey(id))
+	})
+}
+
+// SkipScheduledDate stops the schedules of the account booking the date again, once its
+// booking at the location was cancelled. The skipped dates that are over are dropped
+func (l *Ledger) SkipScheduledDate(account *Account, locationID string, date time.Time) error {
+	schedules, err := l.Schedules()
+
+	if err != nil {
+		return err
+	}
+
+	day := date.Format("Jan 2, 2006")
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+
+	for _, schedule := range schedules {
+		if !schedule.BelongsTo(account) || !strings.EqualFold(schedule.location(account), locationID) {
+			continue
+		}
+
+		if !slices.Contains(schedule.Weekdays, strings.ToLower(date.Weekday().String())) || slices.Contains(schedule.Skip, day) {
+			continue
+		}
+
+		schedule.Skip = slices.DeleteFunc(schedule.Skip, func(skipped string) bool {
+			d, err := time.Parse("Jan 2, 2006", skipped)
+
+			return err == nil && d.Before(yesterday)
+		})
+		schedule.Skip = append(schedule.Skip, day)
+
+		if _, err := l.SaveSchedule(schedule); err != nil {
+			return err
+		}
+
+		log.Println("Schedule", schedule.ID, "of", account.Name, "skips", day)
+	}
+
+	return nil
+}
+
+func getSchedule(bucket *bolt.Bucket, id uint64) (Schedule, error) {
+	var schedule Schedule
+
+	data := bucket.Get(scheduleKey(id))
+
+	if data == nil {
+		return Schedule{}, ErrScheduleNotFound
+	}
+
+	err := json.Unmarshal(data, &schedule)
+
+	return schedule, err
+}
+
+func scheduleKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+
+	return key
+}
+
+// Scheduler periodically books the dates of every schedule that entered the booking window
+type Scheduler struct {
+	accounts     *Accounts
+	client       *WeWorkClient
+	cacheManager *cache.Cache[[]byte]
+	ledger       *Ledger
+	interval     time.Duration
+	trigger      chan struct{}
+}
+
+func NewScheduler(accounts *Accounts, client *WeWorkClient, cacheManager *cache.Cache[[]byte], ledger *Ledger, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		accounts:     accounts,
+		client:       client,
+		cacheManager: cacheManager,
+		ledger:       ledger,
+		interval:     interval,
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// Trigger asks the scheduler to run as soon as possible, e.g. after a schedule changed
+func (s *Scheduler) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default: // A run is already pending
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.runOnce()
+
+		timer := time.NewTimer(s.nextRunIn(time.Now()))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.trigger:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// nextRunIn waits for the interval, or less if a new day enters the booking window before that
+func (s *Scheduler) nextRunIn(now time.Time) time.Duration {
+	now = now.UTC()
+	nextDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 1, 0, 0, time.UTC)
+
+	if wait := nextDay.Sub(now); wait < s.interval {
+		return wait
+	}
+
+	return s.interval
+}
+
+func (s *Scheduler) runOnce() {
+	schedules, err := s.ledger.Schedules()
+
+	if err != nil {
+		log.Println("Scheduler could not read schedules:", err)
+		return
+	}
+
+	now := time.Now()
+
+	datesByAccount := map[*Account]map[scheduledBooking][]string{}
+
+	for _, schedule := range schedules {
+		account, err := s.accounts.Get(ownerName(schedule.Account))
+
+		if err != nil {
+			log.Println("Scheduler skipping schedule", schedule.ID, ":", err)
+			continue
+		}
+
+		locationID := schedule.location(account)
+		booking := schedule.booking(locationID)
+
+		for _, d := range schedule.datesToBook(now) {
+			date := d.Format("Jan 2, 2006")
+
+			entries, err := s.ledger.DateEntries(account.Name, locationID, date)
+
+			if err != nil {
+				log.Println("Scheduler could not read the ledger for", date, ":", err)
+				continue
+			}
+
+			if scheduledDateDone(entries) {
+				continue
+			}
+
+			if datesByAccount[account] == nil {
+				datesByAccount[account] = map[scheduledBooking][]string{}
+			}
+
+			if !slices.Contains(datesByAccount[account][booking], date) {
+				datesByAccount[account][booking] = append(datesByAccount[account][booking], date)
+			}
+		}
+	}
+
+	for account, datesByBooking := range datesByAccount {
+		s.book(account, datesByBooking)
+	}
+}
+
+// The bookings of a scheduled date that may fail before the scheduler gives up on it
+const maxScheduledAttempts = 3
+
+// scheduledDateDone is true when the date needs no more booking: it was booked by the bot
+// or already was, or it failed too many times to keep asking WeWork every run
+func scheduledDateDone(entries []LedgerEntry) bool {
+	failures := 0
+
+	for _, entry := range entries {
+		switch entry.Status {
+		case LedgerStatusBooked, LedgerStatusAlreadyBooked:
+			return true
+		case LedgerStatusFailed:
+			failures++
+		}
+	}
+
+	return failures >= maxScheduledAttempts
+}
+
+func (s *Scheduler) book(account *Account, datesByBooking map[scheduledBooking][]string) {
+	ctx := context.Background()
+
+	for booking, dates := range datesByBooking {
+		log.Println("Scheduler booking", dates, booking.slot, booking.space, "at", booking.locationID, "for", account.Name)
+
+		if err := account.WithToken(ctx, func(bearerToken string) error {
+			_, err := bookDates(ctx, s.client, bearerToken, account.Name, booking.locationID, dates, booking.slot, booking.space, s.cacheManager, s.ledger)
+			return err
+		}); err != nil {
+			log.Println("Scheduler could not book for", account.Name, ":", err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseWeekday(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Weekday
+		hasError bool
+	}{
+		{"tuesday", time.Tuesday, false},
+		{"Thu", time.Thursday, false},
+		{" SAT ", time.Saturday, false},
+		{"t", time.Sunday, true},
+		{"funday", time.Sunday, true},
+	}
+
+	for _, test := range tests {
+		result, err := parseWeekday(test.input)
+
+		if test.hasError {
+			if err == nil {
+				t.Errorf("Expected error for input %s, but got none", test.input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Did not expect error for input %s, but got %v", test.input, err)
+		}
+
+		if result != test.expected {
+			t.Errorf("For input %s, expected %s, but got %s", test.input, test.expected, result)
+		}
+	}
+}
+
+func TestScheduleDatesToBook(t *testing.T) {
+	schedule := Schedule{Weekdays: []string{"tue", "thu"}}
+
+	if err := schedule.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Monday
+	now := time.Date(2025, 2, 17, 10, 0, 0, 0, time.UTC)
+
+	dates := schedule.datesToBook(now)
+
+	if len(dates) != 10 {
+		t.Fatalf("Expected 10 dates, but got %d: %v", len(dates), dates)
+	}
+
+	if first := dates[0].Format("Jan 2, 2006"); first != "Feb 18, 2025" {
+		t.Errorf("Expected first date to be Feb 18, 2025, but got %s", first)
+	}
+
+	// Mar 20 is less than 31 days away, Mar 25 is not
+	if last := dates[len(dates)-1].Format("Jan 2, 2006"); last != "Mar 20, 2025" {
+		t.Errorf("Expected last date to be Mar 20, 2025, but got %s", last)
+	}
+}
+
+func TestScheduleBooking(t *testing.T) {
+	schedule := Schedule{Weekdays: []string{"mon"}, Start: "09:00", End: "09:30", Capacity: 6}
+
+	if err := schedule.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	booking := schedule.booking("loc-a")
+
+	if booking.slot != (TimeSlot{Start: 9 * 60, End: 9*60 + 30}) || booking.space != (SpaceFilter{MinCapacity: 6}) {
+		t.Errorf("Expected a morning in a space for 6, but got %+v", booking)
+	}
+
+	for _, invalid := range []Schedule{
+		{Weekdays: []string{"mon"}, Start: "09:00"},
+		{Weekdays: []string{"mon"}, Capacity: -1},
+	} {
+		if err := invalid.Normalize(); err == nil {
+			t.Errorf("Expected error for %+v", invalid)
+		}
+	}
+}
+
+func TestSchedulerSkipsDoneDates(t *testing.T) {
+	ledger, err := OpenLedger(filepath.Join(t.TempDir(), "webook.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ledger.Close()
+
+	failed := LedgerEntry{Account: "default", LocationID: "loc-a", Date: "Feb 18, 2025", Status: LedgerStatusFailed}
+
+	if scheduledDateDone([]LedgerEntry{failed, failed}) {
+		t.Error("Expected a date that failed twice to be tried again")
+	}
+
+	if !scheduledDateDone([]LedgerEntry{failed, failed, failed}) {
+		t.Error("Expected a date that failed 3 times to be given up")
+	}
+
+	if !scheduledDateDone([]LedgerEntry{{Status: LedgerStatusAlreadyBooked}}) || scheduledDateDone([]LedgerEntry{{Status: LedgerStatusCancelled}}) {
+		t.Error("Expected an existing reservation to be done, and a cancelled one not")
+	}
+
+	account := &Account{Name: "default", Locations: Locations{DefaultID: "loc-a"}}
+	desks, err := ledger.SaveSchedule(Schedule{Account: "default", Weekdays: []string{"tuesday"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elsewhere, err := ledger.SaveSchedule(Schedule{Account: "default", Weekdays: []string{"tuesday"}, LocationID: "loc-b"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tuesday
+	cancelled := time.Date(2025, 2, 18, 0, 0, 0, 0, time.UTC)
+
+	if err := ledger.SkipScheduledDate(account, "LOC-A", cancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	if desks, _ = ledger.Schedule(desks.ID); !slices.Equal(desks.Skip, []string{"Feb 18, 2025"}) {
+		t.Errorf("Expected the cancelled date to be skipped, but got %v", desks.Skip)
+	}
+
+	if elsewhere, _ = ledger.Schedule(elsewhere.ID); len(elsewhere.Skip) != 0 {
+		t.Errorf("Did not expect the schedule of another location to skip the date, but got %v", elsewhere.Skip)
+	}
+
+	for _, d := range desks.datesToBook(time.Date(2025, 2, 17, 10, 0, 0, 0, time.UTC)) {
+		if d.Equal(cancelled) {
+			t.Error("Expected the skipped date not to be booked")
+		}
+	}
+}
+
+func TestSchedulerIgnoresRejectedTokens(t *testing.T) {
+	f := newFakeWeWork(t)
+	client, cacheManager, ledger := newTestBookingDeps(t, f)
+	locationID := f.Space.Location.UUID
+	date := time.Now().UTC().AddDate(0, 0, 1).Format("Jan 2, 2006")
+
+	for range maxScheduledAttempts {
+		if _, err := bookDates(context.Background(), client, "expired", "default", locationID, []string{date}, TimeSlot{}, SpaceFilter{}, cacheManager, ledger); !errors.Is(err, ErrTokenRejected) {
+			t.Fatalf("Expected ErrTokenRejected, but got %v", err)
+		}
+	}
+
+	entries, err := ledger.DateEntries("default", locationID, date)
+
+	if err != nil || len(entries) != 0 || scheduledDateDone(entries) {
+		t.Errorf("Expected the rejected tokens not to count as failed attempts, but got %+v and %v", entries, err)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/eko/gocache/lib/v4/cache"
+)
+
+// SpaceFilter picks the space to book at the location. The zero value books a desk
+type SpaceFilter struct {
+	// A given space, by its UUID or its reservable ID
+	SpaceID string
+	// The fewest seats the space must have
+	MinCapacity int
+}
+
+func newSpaceFilter(spaceID string, minCapacity int) (SpaceFilter, error) {
+	if minCapacity < 0 {
+		return SpaceFilter{}, fmt.Errorf("%w: 'capacity' must not be negative", ErrInvalidSpace)
+	}
+
+	return SpaceFilter{SpaceID: strings.TrimSpace(spaceID), MinCapacity: minCapacity}, nil
+}
+
+// parseCapacity reads the capacity asked for in a query, none when empty
+func parseCapacity(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	capacity, err := strconv.Atoi(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("%w: 'capacity' must be a number", ErrInvalidSpace)
+	}
+
+	return capacity, nil
+}
+
+// IsDefault is true when any desk of the location does, it is then read from the cache
+func (f SpaceFilter) IsDefault() bool {
+	return f.SpaceID == "" && f.MinCapacity == 0
+}
+
+func (f SpaceFilter) Matches(space WeWorkLocation) bool {
+	if f.SpaceID != "" && f.SpaceID != space.UUID && f.SpaceID != space.Reservable.KubeID {
+		return false
+	}
+
+	return space.SeatCapacity() >= f.MinCapacity
+}
+
+func (f SpaceFilter) String() string {
+	description := "desk"
+
+	if f.SpaceID != "" {
+		description += " " + f.SpaceID
+	}
+
+	if f.MinCapacity > 0 {
+		description += fmt.Sprintf(" for %d", f.MinCapacity)
+	}
+
+	return description
+}
+
+// getWeWorkSpaces returns the shared desks of the location, with their availability today
+func getWeWorkSpaces(ctx context.Context, client *WeWorkClient, bearerToken string, coworkingLocationID string) ([]WeWorkLocation, error) {
+	spaces, err := client.GetSpaces(ctx, bearerToken, SpacesQuery{LocationUUIDs: []string{coworkingLocationID}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(spaces, func(space WeWorkLocation) bool {
+		return space.SpaceTypeID != 0 && space.SpaceTypeID != deskSpaceType
+	}), nil
+}
+
+// getBookableSpace returns the space to book, the smallest one matching the filter so
+// the large spaces stay free for the large teams
+func getBookableSpace(ctx context.Context, client *WeWorkClient, cacheManager *cache.Cache[[]byte], bearerToken string, coworkingLocationID string, filter SpaceFilter) (WeWorkLocation, error) {
+	if filter.IsDefault() {
+		return getWeWorkLocation(ctx, client, cacheManager, bearerToken, coworkingLocationID)
+	}
+
+	spaces, err := getWeWorkSpaces(ctx, client, bearerToken, coworkingLocationID)
+
+	if err != nil {
+		return WeWorkLocation{}, err
+	}
+
+	var found *WeWorkLocation
+
+	for i, space := range spaces {
+		if filter.Matches(space) && (found == nil || space.SeatCapacity() < found.SeatCapacity()) {
+			found = &spaces[i]
+		}
+	}
+
+	if found == nil {
+		return WeWorkLocation{}, fmt.Errorf("%w: no %s at %s", ErrSpaceNotFound, filter, coworkingLocationID)
+	}
+
+	return *found, nil
+}
+
+type SpaceResult struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	LocationID     string `json:"locationId"`
+	LocationName   string `json:"locationName"`
+	Capacity       int    `json:"capacity"`
+	Credits        int    `json:"credits"`
+	SeatsAvailable int    `json:"seatsAvailable"`
+}
+
+// listSpaces returns the spaces of the location matching the filter, smallest first
+func listSpaces(ctx context.Context, client *WeWorkClient, bearerToken string, coworkingLocationID string, filter SpaceFilter) ([]SpaceResult, error) {
+	spaces, err := getWeWorkSpaces(ctx, client, bearerToken, coworkingLocationID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SpaceResult{}
+
+	for _, space := range spaces {
+		if !filter.Matches(space) {
+			continue
+		}
+
+		name := space.Name
+
+		if name == "" {
+			name = space.Location.Name
+		}
+
+		results = append(results, SpaceResult{
+			ID:             space.UUID,
+			Name:           name,
+			LocationID:     space.Location.UUID,
+			LocationName:   space.Location.Name,
+			Capacity:       space.SeatCapacity(),
+			Credits:        space.Credits,
+			SeatsAvailable: space.Seat.Available,
+		})
+	}
+
+	slices.SortStableFunc(results, func(a, b SpaceResult) int {
+		return a.Capacity - b.Capacity
+	})
+
+	return results, nil
+}
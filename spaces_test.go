@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewSpaceFilter(t *testing.T) {
+	if filter, err := newSpaceFilter(" space-a ", 4); err != nil || filter != (SpaceFilter{SpaceID: "space-a", MinCapacity: 4}) {
+		t.Errorf("Expected a filter on space-a for 4, but got %+v and %v", filter, err)
+	}
+
+	if _, err := newSpaceFilter("", -1); !errors.Is(err, ErrInvalidSpace) {
+		t.Errorf("Expected ErrInvalidSpace for a negative capacity, but got %v", err)
+	}
+}
+
+func newFakeSpace(f *fakeWeWork, uuid string, name string, capacity int, credits int) WeWorkLocation {
+	space := f.Space
+	space.UUID = uuid
+	space.Name = name
+	space.Capacity = capacity
+	space.Credits = credits
+	space.SpaceTypeID = deskSpaceType
+	space.Reservable.KubeID = uuid + "-kube"
+
+	return space
+}
+
+func TestSpaceFilterBooking(t *testing.T) {
+	f := newFakeWeWork(t)
+	f.Spaces = []WeWorkLocation{
+		newFakeSpace(f, "space-large", "Open space", 12, 8),
+		newFakeSpace(f, "space-small", "Nook", 4, 2),
+		newFakeSpace(f, "space-medium", "Corner", 6, 4),
+	}
+
+	client, cacheManager, ledger := newTestBookingDeps(t, f)
+	ctx := context.Background()
+	locationID := f.Space.Location.UUID
+
+	spaces, err := listSpaces(ctx, client, f.AccessToken, locationID, SpaceFilter{MinCapacity: 5})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spaces) != 2 || spaces[0].ID != "space-medium" || spaces[0].Name != "Corner" || spaces[1].ID != "space-large" {
+		t.Errorf("Expected the spaces for 5 people, smallest first, but got %+v", spaces)
+	}
+
+	date := time.Now().UTC().AddDate(0, 0, 1).Format("Jan 2, 2006")
+	morning := TimeSlot{Start: 9 * 60, End: 12 * 60}
+
+	results, err := bookDates(ctx, client, f.AccessToken, "default", locationID, []string{date}, morning, SpaceFilter{MinCapacity: 5}, cacheManager, ledger)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Status != BookingResultBooked {
+		t.Errorf("Expected the space to be booked, but got %+v", results[0])
+	}
+
+	// Any desk counts as booked for the day
+	results, err = bookDates(ctx, client, f.AccessToken, "default", locationID, []string{date}, TimeSlot{}, SpaceFilter{}, cacheManager, ledger)
+
+	if err != nil || results[0].Status != BookingResultAlreadyBooked {
+		t.Errorf("Expected the day to be already booked, but got %+v and %v", results, err)
+	}
+
+	bookings := f.Bookings()
+
+	if len(bookings) != 1 || bookings[0].WeworkUUID != "space-medium" || bookings[0].SpaceType != deskSpaceType {
+		t.Errorf("Expected the smallest space for 5 to be sent to WeWork, but got %+v", bookings)
+	}
+
+	if space, err := getBookableSpace(ctx, client, cacheManager, f.AccessToken, locationID, SpaceFilter{SpaceID: "space-large-kube"}); err != nil || space.UUID != "space-large" {
+		t.Errorf("Expected the space by its reservable ID, but got %+v and %v", space, err)
+	}
+
+	if _, err := getBookableSpace(ctx, client, cacheManager, f.AccessToken, locationID, SpaceFilter{MinCapacity: 20}); !errors.Is(err, ErrSpaceNotFound) {
+		t.Errorf("Expected no space for 20, but got %v", err)
+	}
+
+	booking, _, err := cancelBooking(ctx, client, f.AccessToken, "default", locationID, date, "", ledger)
+
+	if err != nil || booking.WeworkUUID != "space-medium" {
+		t.Errorf("Expected the space booking to be cancelled, but got %+v and %v", booking, err)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/eko/gocache/lib/v4/cache"
+	bolt "go.etcd.io/bbolt"
+)
+
+var waitlistBucket = []byte("waitlist")
+
+var ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+
+const WaitlistStatusWaiting = "waiting"
+const WaitlistStatusBooked = "booked"
+const WaitlistStatusExpired = "expired"
+const WaitlistStatusFailed = "failed"
+
+// WaitlistEntry is a date that was full when asked for, booked as soon as a seat frees up
+type WaitlistEntry struct {
+	ID         uint64 `json:"id"`
+	Account    string `json:"account"`
+	LocationID string `json:"locationId"`
+	Date       string `json:"date"`
+	Start      string `json:"start,omitempty"`
+	End        string `json:"end,omitempty"`
+	SpaceID    string `json:"spaceId,omitempty"`
+	Capacity   int    `json:"capacity,omitempty"`
+	Status     string `json:"status"`
+	// The bookings tried while waiting, only the last one is recorded in the ledger
+	Attempts      int       `json:"attempts,omitempty"`
+	ReservationID string    `json:"reservationId,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// BelongsTo checks the entry is owned by the account
+func (e WaitlistEntry) BelongsTo(account *Account) bool {
+	return ownerName(e.Account) == account.Name
+}
+
+// booking is what the entry books, it was validated with the booking request
+func (e WaitlistEntry) booking() (TimeSlot, SpaceFilter) {
+	slot, _ := parseTimeSlot(e.Start, e.End)
+	space, _ := newSpaceFilter(e.SpaceID, e.Capacity)
+
+	return slot, space
+}
+
+// SaveWaitlistEntry creates the entry when it has no ID, otherwise replaces the existing one
+func (l *Ledger) SaveWaitlistEntry(entry WaitlistEntry) (WaitlistEntry, error) {
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(waitlistBucket)
+		now := time.Now().UTC()
+
+		if entry.ID == 0 {
+			id, err := bucket.NextSequence()
+
+			if err != nil {
+				return err
+			}
+
+			entry.ID = id
+			entry.CreatedAt = now
+		} else if bucket.Get(waitlistKey(entry.ID)) == nil {
+			// Deleted while it was being checked
+			return ErrWaitlistEntryNotFound
+		}
+
+		entry.UpdatedAt = now
+
+		data, err := json.Marshal(entry)
+
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(waitlistKey(entry.ID), data)
+	})
+
+	return entry, err
+}
+
+// WaitlistEntries returns every entry, oldest first
+func (l *Ledger) WaitlistEntries() ([]WaitlistEntry, error) {
+	entries := []WaitlistEntry{}
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(waitlistBucket).ForEach(func(k, v []byte) error {
+			var entry WaitlistEntry
+
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+func (l *Ledger) WaitlistEntry(id uint64) (WaitlistEntry, error) {
+	var entry WaitlistEntry
+
+	err := l.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(waitlistBucket).Get(waitlistKey(id))
+
+		if data == nil {
+			return ErrWaitlistEntryNotFound
+		}
+
+		return json.Unmarshal(data, &entry)
+	})
+
+	return entry, err
+}
+
+func (l *Ledger) DeleteWaitlistEntry(id uint64) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(waitlistBucket)
+
+		if bucket.Get(waitlistKey(id)) == nil {
+			return ErrWaitlistEntryNotFound
+		}
+
+		return bucket.Delete(waitlistKey(id))
+	})
+}
+
+// Waitlist polls the availability of the waiting entries and books them the moment a
+// seat frees up, then notifies the requester
+type Waitlist struct {
+	accounts     *Accounts
+	client       *WeWorkClient
+	cacheManager *cache.Cache[[]byte]
+	ledger       *Ledger
+	interval     time.Duration
+	notifier     *Notifier
+}
+
+func NewWaitlist(accounts *Accounts, client *WeWorkClient, cacheManager *cache.Cache[[]byte], ledger *Ledger, interval time.Duration, notifier *Notifier) *Waitlist {
+	return &Waitlist{
+		accounts:     accounts,
+		client:       client,
+		cacheManager: cacheManager,
+		ledger:       ledger,
+		interval:     interval,
+		notifier:     notifier,
+	}
+}
+
+// Add waits for a seat on the date, the entry is checked on the next poll
+func (w *Waitlist) Add(entry WaitlistEntry) (WaitlistEntry, error) {
+	entry.ID = 0
+	entry.Status = WaitlistStatusWaiting
+
+	return w.ledger.SaveWaitlistEntry(entry)
+}
+
+func (w *Waitlist) Run(ctx context.Context) {
+	for {
+		w.runOnce(ctx)
+
+		timer := time.NewTimer(w.interval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *Waitlist) runOnce(ctx context.Context) {
+	entries, err := w.ledger.WaitlistEntries()
+
+	if err != nil {
+		log.Println("Waitlist could not read entries:", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.Status != WaitlistStatusWaiting {
+			continue
+		}
+
+		account, err := w.accounts.Get(ownerName(entry.Account))
+
+		if err != nil {
+			log.Println("Waitlist skipping entry", entry.ID, ":", err)
+			continue
+		}
+
+		if err := account.WithToken(ctx, func(bearerToken string) error {
+			var err error
+
+			entry, err = w.process(ctx, bearerToken, entry, time.Now())
+
+			return err
+		}); err != nil {
+			log.Println("Waitlist could not check entry", entry.ID, ":", err)
+			continue
+		}
+
+		w.save(ctx, entry)
+	}
+}
+
+// process books the entry when a seat is available. It stays waiting while the space
+// is full or the failure is temporary, and expires once the day is over. ErrTokenRejected
+// is returned so it is processed again with a new token
+func (w *Waitlist) process(ctx context.Context, bearerToken string, entry WaitlistEntry, now time.Time) (WaitlistEntry, error) {
+	// Parsed when the entry was added
+	d, _ := time.Parse("Jan 2, 2006", entry.Date)
+
+	if now.UTC().After(d.AddDate(0, 0, 1)) {
+		entry.Status = WaitlistStatusExpired
+		return entry, nil
+	}
+
+	slot, space := entry.booking()
+
+	// doBooking checks it as well, this saves a booking attempt on every poll
+	if err := ensureAvailable(ctx, w.client, bearerToken, entry.LocationID, d, space, now); errors.Is(err, ErrNoSeatsAvailable) {
+		return entry, nil
+	} else if errors.Is(err, ErrTokenRejected) {
+		return entry, err
+	}
+
+	log.Println("Waitlist booking", entry.Date, "at", entry.LocationID, "for", entry.Account)
+
+	bookingResponse, err := doBooking(ctx, w.client, bearerToken, entry.Account, entry.LocationID, entry.Date, slot, space, w.cacheManager, w.ledger)
+	entry.Attempts++
+
+	switch {
+	case err == nil, errors.Is(err, ErrAlreadyBooked):
+		entry.Status = WaitlistStatusBooked
+		entry.ReservationID = bookingResponse.ReservationID
+		entry.Error = ""
+	case errors.Is(err, ErrTokenRejected):
+		return entry, err
+	case errors.Is(err, ErrNoSeatsAvailable), isRetryable(err):
+		// Still waiting, the attempt is only kept on the entry
+		entry.Error = err.Error()
+		return entry, nil
+	default:
+		entry.Status = WaitlistStatusFailed
+		entry.Error = err.Error()
+	}
+
+	recordBooking(w.ledger, entry.Account, entry.LocationID, entry.Date, space, bookingResponse, err)
+
+	return entry, nil
+}
+
+// isRetryable is true when the same booking may succeed later, like the v1 API tells
+func isRetryable(err error) bool {
+	_, apiError := classifyError(err, http.StatusInternalServerError)
+
+	return apiError.Retryable
+}
+
+func waitlistKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+
+	return key
+}
+
+// save stores the entry, and notifies the requester once it is no longer waiting
+func (w *Waitlist) save(ctx context.Context, entry WaitlistEntry) {
+	if _, err := w.ledger.SaveWaitlistEntry(entry); err != nil {
+		log.Println("Waitlist could not save entry", entry.ID, ":", err)
+
+		// Not notified when it was booked, the ledger still has the booking
+		if errors.Is(err, ErrWaitlistEntryNotFound) {
+			return
+		}
+	}
+
+	if entry.Status != WaitlistStatusWaiting {
+		log.Println("Waitlist entry", entry.ID, "for", entry.Date, entry.Status, entry.ReservationID, entry.Error)
+		w.notifier.Notify(ctx, entry)
+	}
+}
+
+// The longest a notification may take, it is not sent again
+const notifyTimeout = 10 * time.Second
+
+// Notifier posts the waitlist entries that were booked, expired or failed as JSON to the
+// configured URL. Requests cannot pick their own, the server would post wherever it is told
+type Notifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewNotifier(url string) *Notifier {
+	return &Notifier{url: url, client: &http.Client{Timeout: notifyTimeout}}
+}
+
+// WaitlistNotification is the body of the notification
+type WaitlistNotification struct {
+	Event string        `json:"event"`
+	Entry WaitlistEntry `json:"entry"`
+}
+
+func (n *Notifier) Notify(ctx context.Context, entry WaitlistEntry) {
+	if n.url == "" {
+		return
+	}
+
+	body, err := json.Marshal(WaitlistNotification{Event: "waitlist." + entry.Status, Entry: entry})
+
+	if err != nil {
+		log.Println("Could not encode notification:", err)
+		return
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+
+	if err != nil {
+		log.Println("Could not notify", n.url, ":", err)
+		return
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", weworkUserAgent)
+
+	response, err := n.client.Do(request)
+
+	if err != nil {
+		log.Println("Could not notify", n.url, ":", err)
+		return
+	}
+
+	response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		log.Println("Notification to", n.url, "answered", response.Status)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWaitlistBooksWhenSeatFreesUp(t *testing.T) {
+	f := newFakeWeWork(t)
+	f.Space.Seat.Total = 10
+
+	client, cacheManager, ledger := newTestBookingDeps(t, f)
+	ctx := context.Background()
+	locationID := f.Space.Location.UUID
+	// WeWork only tells the seats left today
+	date := time.Now().UTC().Format("Jan 2, 2006")
+
+	results, err := bookDates(ctx, client, f.AccessToken, "default", locationID, []string{date}, TimeSlot{}, SpaceFilter{}, cacheManager, ledger)
+
+	if err != nil || !errors.Is(results[0].err, ErrNoSeatsAvailable) || len(f.Bookings()) != 0 {
+		t.Fatalf("Expected the full desk not to be booked, but got %+v and %v", results, err)
+	}
+
+	notifications := make(chan WaitlistNotification, 1)
+	notifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification WaitlistNotification
+
+		json.NewDecoder(r.Body).Decode(&notification)
+		notifications <- notification
+	}))
+	t.Cleanup(notifyServer.Close)
+
+	waitlist := NewWaitlist(nil, client, cacheManager, ledger, time.Minute, NewNotifier(notifyServer.URL))
+	entry, err := waitlist.Add(WaitlistEntry{Account: "default", LocationID: locationID, Date: date})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err = waitlist.process(ctx, f.AccessToken, entry, time.Now())
+
+	if err != nil || entry.Status != WaitlistStatusWaiting {
+		t.Fatalf("Expected the entry to keep waiting, but got %+v and %v", entry, err)
+	}
+
+	// WeWork failing for a moment keeps the entry waiting, without a ledger entry every poll
+	f.Space.Seat.Available = 1
+	f.FailBookings = 1
+
+	entry, err = waitlist.process(ctx, f.AccessToken, entry, time.Now())
+
+	if err != nil || entry.Status != WaitlistStatusWaiting || entry.Attempts != 1 || entry.Error == "" {
+		t.Fatalf("Expected the entry to keep waiting after a failed attempt, but got %+v and %v", entry, err)
+	}
+
+	entry, err = waitlist.process(ctx, f.AccessToken, entry, time.Now())
+
+	if err != nil || entry.Status != WaitlistStatusBooked || entry.ReservationID != "reservation-1" || entry.Attempts != 2 {
+		t.Fatalf("Expected the entry to be booked, but got %+v and %v", entry, err)
+	}
+
+	if entries, err := ledger.Entries("default"); err != nil || len(entries) != 2 || entries[1].Status != LedgerStatusBooked {
+		t.Errorf("Expected only the failed booking and the waitlisted one in the ledger, but got %+v and %v", entries, err)
+	}
+
+	waitlist.save(ctx, entry)
+
+	select {
+	case notification := <-notifications:
+		if notification.Event != "waitlist.booked" || notification.Entry.ID != entry.ID {
+			t.Errorf("Unexpected notification %+v", notification)
+		}
+	default:
+		t.Error("Expected the booking to be notified")
+	}
+
+	if saved, err := ledger.WaitlistEntry(entry.ID); err != nil || saved.Status != WaitlistStatusBooked {
+		t.Errorf("Expected the booked entry to be saved, but got %+v and %v", saved, err)
+	}
+
+	expired, err := waitlist.process(ctx, f.AccessToken, WaitlistEntry{Account: "default", LocationID: locationID, Date: date}, time.Now().AddDate(0, 0, 3))
+
+	if err != nil || expired.Status != WaitlistStatusExpired {
+		t.Errorf("Expected the entry to expire after the day, but got %+v and %v", expired, err)
+	}
+
+	if err := ledger.DeleteWaitlistEntry(entry.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ledger.SaveWaitlistEntry(entry); !errors.Is(err, ErrWaitlistEntryNotFound) {
+		t.Errorf("Expected a deleted entry not to be saved again, but got %v", err)
+	}
+}
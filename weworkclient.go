@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"resty.dev/v3"
+)
+
+// DefaultWeWorkURL can be replaced to run against a stand-in of WeWork, e.g. in the tests
+const DefaultWeWorkURL = "https://members.wework.com"
+
+func weworkAPIURL(weworkURL string) string {
+	return weworkURL + "/workplaceone/api"
+}
+
+// weworkSupportPageURL is the page used to log in and read the tokens
+func weworkSupportPageURL(weworkURL string) string {
+	return weworkURL + "/workplaceone/content2/wework-support"
+}
+
+// weworkOrigin is where the browser lands once logged in, the login pages are on another domain
+func weworkOrigin(weworkURL string) string {
+	u, err := url.Parse(weworkURL)
+
+	if err != nil {
+		return weworkURL
+	}
+
+	return u.Scheme + "://" + u.Host
+}
+
+// WeWork answers in a few hundred milliseconds, a request taking longer is retried
+const weworkRequestTimeout = 30 * time.Second
+
+const weworkUserAgent = "webook (+https://github.com/jeromewir/webook)"
+
+// WeWorkClient calls the WeWork API with a single configured resty client. Every
+// method takes the access token of the account it acts for
+type WeWorkClient struct {
+	client *resty.Client
+	retry  RetryPolicy
+	// The monthly credits the bookings may spend for each account
+	budget CreditBudget
+}
+
+// NewWeWorkClient logs every request and response when debug is set, with the tokens redacted
+func NewWeWorkClient(baseURL string, retry RetryPolicy, budget CreditBudget, debug bool) *WeWorkClient {
+	client := resty.New().
+		SetBaseURL(strings.TrimSuffix(baseURL, "/")).
+		SetTimeout(weworkRequestTimeout).
+		SetHeader("User-Agent", weworkUserAgent).
+		SetDebug(debug).
+		SetDebugLogFormatter(redactedDebugLog)
+
+	return &WeWorkClient{client: client, retry: retry, budget: budget}
+}
+
+func (c *WeWorkClient) Close() error {
+	return c.client.Close()
+}
+
+// request always decodes the response as JSON, whatever content type WeWork sends
+func (c *WeWorkClient) request(ctx context.Context, token string) *resty.Request {
+	return c.client.R().
+		SetContext(ctx).
+		SetAuthToken(token).
+		SetForceResponseContentType("application/json")
+}
+
+// get sends the request again when it fails for a temporary reason, which is safe as it changes nothing
+func (c *WeWorkClient) get(ctx context.Context, token string, action string, path string, query map[string]string, result any) error {
+	return c.retry.Do(ctx, action, func(int) error {
+		response, err := c.request(ctx, token).
+			SetQueryParams(query).
+			SetResult(result).
+			Get(path)
+
+		if err != nil {
+			return err
+		}
+
+		return checkResponse(response, action)
+	})
+}
+
+// SpacesQuery filters the spaces, on their location or with a free text search. Shared
+// desks may be returned without a SpaceTypeID, and the availability is the one of today
+type SpacesQuery struct {
+	LocationUUIDs []string
+	SearchText    string
+}
+
+func (c *WeWorkClient) GetSpaces(ctx context.Context, token string, query SpacesQuery) ([]WeWorkLocation, error) {
+	params := map[string]string{}
+
+	if len(query.LocationUUIDs) > 0 {
+		params["locationUUIDs"] = strings.Join(query.LocationUUIDs, ",")
+	}
+
+	if query.SearchText != "" {
+		params["searchText"] = query.SearchText
+	}
+
+	var locationsResponse WeWorkLocationsResponse
+
+	if err := c.get(ctx, token, "fetching locations", "/spaces/get-spaces", params, &locationsResponse); err != nil {
+		return nil, err
+	}
+
+	return locationsResponse.GetSharedWorkspaces.Workspaces, nil
+}
+
+func (c *WeWorkClient) ListBookings(ctx context.Context, token string) ([]WeWorkBooking, error) {
+	var bookingsResponse WeWorkBookingsResponse
+
+	if err := c.get(ctx, token, "fetching bookings", "/common-booking/upcoming-bookings", nil, &bookingsResponse); err != nil {
+		return nil, err
+	}
+
+	return bookingsResponse.Bookings, nil
+}
+
+// CreateBooking is not retried here, as a failed request may still have booked the space.
+// doBooking only sends it again once WeWork tells the space was not booked
+func (c *WeWorkClient) CreateBooking(ctx context.Context, token string, booking BookingRequest) (BookingResponse, error) {
+	var bookingResponse BookingResponse
+
+	response, err := c.request(ctx, token).
+		SetBody(booking).
+		SetResult(&bookingResponse).
+		Post("/common-booking/")
+
+	if err != nil {
+		return BookingResponse{}, err
+	}
+
+	if err := checkResponse(response, "making booking request"); err != nil {
+		return BookingResponse{}, err
+	}
+
+	if bookingResponse.BookingStatus != "BookingSuccess" {
+		if isNoSeatsError(bookingResponse.Errors) {
+			return bookingResponse, fmt.Errorf("%w: %v", ErrNoSeatsAvailable, bookingResponse.Errors)
+		}
+
+		return bookingResponse, &ErrBookingRejected{Errors: bookingResponse.Errors}
+	}
+
+	return bookingResponse, nil
+}
+
+// CancelBooking is not retried, WeWork may have cancelled the booking even though the request failed
+func (c *WeWorkClient) CancelBooking(ctx context.Context, token string, cancellation CancelBookingRequest) (CancelBookingResponse, error) {
+	var cancelResponse CancelBookingResponse
+
+	response, err := c.request(ctx, token).
+		SetBody(cancellation).
+		SetResult(&cancelResponse).
+		Post("/common-booking/cancel")
+
+	if err != nil {
+		return CancelBookingResponse{}, err
+	}
+
+	if err := checkResponse(response, "making cancel request"); err != nil {
+		return CancelBookingResponse{}, err
+	}
+
+	if cancelResponse.CancellationStatus != "CancellationSuccess" {
+		return CancelBookingResponse{}, fmt.Errorf("cancellation not confirmed: %v", cancelResponse.Errors)
+	}
+
+	return cancelResponse, nil
+}
+
+var tokenFieldRegexp = regexp.MustCompile(`("(?:access_token|refresh_token|id_token|accessToken|refreshToken)"\s*:\s*")[^"]*"`)
+
+// redactedDebugLog is the resty debug log without the tokens, the headers are already redacted by resty
+func redactedDebugLog(debugLog *resty.DebugLog) string {
+	debugLog.Request.Body = tokenFieldRegexp.ReplaceAllString(debugLog.Request.Body, `${1}<redacted>"`)
+	debugLog.Response.Body = tokenFieldRegexp.ReplaceAllString(debugLog.Response.Body, `${1}<redacted>"`)
+
+	return resty.DebugLogFormatter(debugLog)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestWeWorkClient(t *testing.T, handler http.HandlerFunc) *WeWorkClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewWeWorkClient(server.URL, RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}, CreditBudget{}, false)
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func TestWeWorkClientGetSpaces(t *testing.T) {
+	client := newTestWeWorkClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/spaces/get-spaces" {
+			t.Errorf("Expected /spaces/get-spaces, but got %s", r.URL.Path)
+		}
+
+		if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+			t.Errorf("Expected the bearer token, but got %q", auth)
+		}
+
+		if ids := r.URL.Query().Get("locationUUIDs"); ids != "a,b" {
+			t.Errorf("Expected the location UUIDs, but got %q", ids)
+		}
+
+		w.Write([]byte(`{"getSharedWorkspaces":{"workspaces":[{"uuid":"space-a"},{"uuid":"space-b"}]}}`))
+	})
+
+	spaces, err := client.GetSpaces(context.Background(), "token", SpacesQuery{LocationUUIDs: []string{"a", "b"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spaces) != 2 || spaces[0].UUID != "space-a" {
+		t.Errorf("Expected the 2 spaces, but got %+v", spaces)
+	}
+}
+
+func TestWeWorkClientRetriesSlowRequests(t *testing.T) {
+	var requests atomic.Int32
+
+	client := newTestWeWorkClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+
+			return
+		}
+
+		w.Write([]byte(`{"getSharedWorkspaces":{"workspaces":[{"uuid":"space-a"}]}}`))
+	})
+	client.client.SetTimeout(50 * time.Millisecond)
+
+	spaces, err := client.GetSpaces(context.Background(), "token", SpacesQuery{LocationUUIDs: []string{"a"}})
+
+	if err != nil || len(spaces) != 1 || requests.Load() != 2 {
+		t.Errorf("Expected the timed out request to be retried, but got %+v and %v after %d requests", spaces, err, requests.Load())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	requests.Store(0)
+
+	if _, err := client.GetSpaces(ctx, "token", SpacesQuery{LocationUUIDs: []string{"a"}}); !errors.Is(err, context.DeadlineExceeded) || requests.Load() != 1 {
+		t.Errorf("Expected no retry once the caller gave up, but got %v after %d requests", err, requests.Load())
+	}
+}
+
+func TestWeWorkClientCreateBooking(t *testing.T) {
+	var requests []BookingRequest
+
+	client := newTestWeWorkClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var request BookingRequest
+
+		if r.Method != http.MethodPost || r.URL.Path != "/common-booking/" {
+			t.Errorf("Expected POST /common-booking/, but got %s %s", r.Method, r.URL.Path)
+		}
+
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		switch request.LocationID {
+		case "full":
+			w.Write([]byte(`{"BookingStatus":"BookingFailed","Errors":["No seats available"]}`))
+		case "closed":
+			w.Write([]byte(`{"BookingStatus":"BookingFailed","Errors":["Space is closed"]}`))
+		default:
+			w.Write([]byte(`{"BookingStatus":"BookingSuccess","ReservationID":"res-1"}`))
+		}
+	})
+
+	ctx := context.Background()
+
+	response, err := client.CreateBooking(ctx, "token", BookingRequest{LocationID: "open"})
+
+	if err != nil || response.ReservationID != "res-1" {
+		t.Errorf("Expected reservation res-1, but got %+v and %v", response, err)
+	}
+
+	if _, err := client.CreateBooking(ctx, "token", BookingRequest{LocationID: "full"}); !errors.Is(err, ErrNoSeatsAvailable) {
+		t.Errorf("Expected ErrNoSeatsAvailable, but got %v", err)
+	}
+
+	var rejectedErr *ErrBookingRejected
+
+	if _, err := client.CreateBooking(ctx, "token", BookingRequest{LocationID: "closed"}); !errors.As(err, &rejectedErr) {
+		t.Errorf("Expected ErrBookingRejected, but got %v", err)
+	}
+
+	if len(requests) != 3 {
+		t.Errorf("Expected a request per booking, but got %d", len(requests))
+	}
+}
+
+func TestWeWorkClientListBookings(t *testing.T) {
+	calls := 0
+
+	client := newTestWeWorkClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		// The first call fails, it is retried
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"bookings":[{"reservationId":"res-1","locationId":"loc-1"}]}`))
+	})
+
+	bookings, err := client.ListBookings(context.Background(), "token")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 || len(bookings) != 1 || bookings[0].ReservationID != "res-1" {
+		t.Errorf("Expected the booking after a retry, but got %d calls and %+v", calls, bookings)
+	}
+}
+
+func TestWeWorkClientCancelBooking(t *testing.T) {
+	calls := 0
+
+	client := newTestWeWorkClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		if calls == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path != "/common-booking/cancel" {
+			t.Errorf("Expected /common-booking/cancel, but got %s", r.URL.Path)
+		}
+
+		w.Write([]byte(`{"CancellationStatus":"CancellationSuccess","CreditsRefunded":2}`))
+	})
+
+	ctx := context.Background()
+	cancellation := newCancelBookingRequest(WeWorkBooking{ReservationID: "res-1"})
+
+	if _, err := client.CancelBooking(ctx, "expired", cancellation); !errors.Is(err, ErrTokenRejected) {
+		t.Errorf("Expected ErrTokenRejected, but got %v", err)
+	}
+
+	response, err := client.CancelBooking(ctx, "token", cancellation)
+
+	if err != nil || response.CreditsRefunded != 2 {
+		t.Errorf("Expected 2 credits refunded, but got %+v and %v", response, err)
+	}
+}
+
+func TestWeWorkClientDebugRedactsTokens(t *testing.T) {
+	var output bytes.Buffer
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"bookings":[],"access_token":"response-secret"}`))
+	}))
+	defer server.Close()
+
+	client := NewWeWorkClient(server.URL, DefaultRetryPolicy(), CreditBudget{}, true)
+	defer client.Close()
+
+	client.client.SetLogger(testLogger{&output})
+
+	if _, err := client.ListBookings(context.Background(), "header-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := output.String()
+
+	if !strings.Contains(logged, "upcoming-bookings") {
+		t.Fatalf("Expected the request to be logged, but got %s", logged)
+	}
+
+	for _, secret := range []string{"header-secret", "response-secret"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("Expected %s to be redacted, but got %s", secret, logged)
+		}
+	}
+}
+
+type testLogger struct {
+	output *bytes.Buffer
+}
+
+func (l testLogger) Errorf(format string, v ...any) { l.write(format, v...) }
+func (l testLogger) Warnf(format string, v ...any)  { l.write(format, v...) }
+func (l testLogger) Debugf(format string, v ...any) { l.write(format, v...) }
+
+func (l testLogger) write(format string, v ...any) {
+	fmt.Fprintf(l.output, format, v...)
+}
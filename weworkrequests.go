@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/chromedp/chromedp"
 	"resty.dev/v3"
 )
 
@@ -17,7 +20,9 @@ type WeWorkLocation struct {
 		CwmSpaceID    int    `json:"cwmSpaceId"`
 		CwmSpaceCount int    `json:"cwmSpaceCount"`
 	} `json:"reservable"`
-	UUID           string `json:"uuid"`
+	UUID string `json:"uuid"`
+	// Some spaces have their own name, the others are named after their location
+	Name           string `json:"name"`
 	InventoryUUID  string `json:"inventoryUuid"`
 	ImageURL       string `json:"imageUrl"`
 	HeaderImageURL string `json:"headerImageUrl"`
@@ -79,7 +84,7 @@ type WeWorkLocation struct {
 		Distance               float32 `json:"distance"`
 		HasThirdPartyDisplay   bool    `json:"hasThirdPartyDisplay"`
 		IsMigrated             bool    `json:"isMigrated"`
-		SpaceAvailabilityCount int     `json:"spaceAvailabilityCount"`
+		SpaceAvailabilityCount *int    `json:"spaceAvailabilityCount"`
 		Franchise              string  `json:"franchise"`
 		AccountType            int     `json:"accountType"`
 		AffiliateSpaceType     int     `json:"affiliateSpaceType"`
@@ -111,13 +116,44 @@ type WeWorkLocation struct {
 		Total     int `json:"total"`
 		Available int `json:"available"`
 	} `json:"seat"`
-	SeatsAvailable     int  `json:"seatsAvailable"`
+	// Missing when WeWork does not tell, see AvailableSeats
+	SeatsAvailable     *int `json:"seatsAvailable"`
 	Order              int  `json:"order"`
 	IsHybridSpace      bool `json:"isHybridSpace"`
 	AffiliateSpaceType int  `json:"affiliateSpaceType"`
 	SpaceTypeID        int  `json:"SpaceTypeID"`
 }
 
+// SeatCapacity is how many people the space holds
+func (s WeWorkLocation) SeatCapacity() int {
+	return max(s.Capacity, s.Reservable.Capacity)
+}
+
+// checkResponse turns error statuses into errors, a rejected token is reported
+// with ErrTokenRejected so a new one can be fetched
+func checkResponse(response *resty.Response, action string) error {
+	if response.StatusCode() == http.StatusUnauthorized {
+		return ErrTokenRejected
+	}
+
+	if response.IsError() {
+		body := response.String()
+
+		if len(body) > maxErrorBodySize {
+			body = body[:maxErrorBodySize]
+		}
+
+		return &ErrWeWorkHTTP{
+			Action:     action,
+			Status:     response.StatusCode(),
+			Body:       body,
+			RetryAfter: parseRetryAfter(response.Header().Get("Retry-After"), time.Now()),
+		}
+	}
+
+	return nil
+}
+
 type WeWorkLocationsResponse struct {
 	Limit               int `json:"limit"`
 	Offset              int `json:"offset"`
@@ -126,60 +162,90 @@ type WeWorkLocationsResponse struct {
 	} `json:"getSharedWorkspaces"`
 }
 
-func FetchWeWorkLocation(ctx context.Context, token string, locationID string) (WeWorkLocation, error) {
-	request := resty.New().R().SetContext(ctx).SetAuthToken(token)
+func FetchWeWorkLocation(ctx context.Context, client *WeWorkClient, token string, locationID string) (WeWorkLocation, error) {
+	locations, err := client.GetSpaces(ctx, token, SpacesQuery{LocationUUIDs: []string{locationID}})
 
-	var locationsResponse WeWorkLocationsResponse
+	if err != nil {
+		return WeWorkLocation{}, err
+	}
 
-	response, err := request.SetResult(&locationsResponse).
-		Get(fmt.Sprintf("https://members.wework.com/workplaceone/api/spaces/get-spaces?locationUUIDs=%s", locationID))
+	if len(locations) == 0 {
+		return WeWorkLocation{}, errors.New("no locations found")
+	}
+
+	return locations[0], nil
+}
+
+// SearchWeWorkLocations returns the workspaces whose location name or address matches the query
+func SearchWeWorkLocations(ctx context.Context, client *WeWorkClient, token string, query string) ([]WeWorkLocation, error) {
+	workspaces, err := client.GetSpaces(ctx, token, SpacesQuery{SearchText: query})
 
 	if err != nil {
-		return WeWorkLocation{}, err
+		return nil, err
 	}
 
-	if response.IsError() {
-		return WeWorkLocation{}, fmt.Errorf("error fetching locations: %s", response.Status())
+	// The search is also applied here as the API can return nearby locations
+	var locations []WeWorkLocation
+
+	for _, location := range workspaces {
+		if location.Matches(query) {
+			locations = append(locations, location)
+		}
 	}
 
-	if len(locationsResponse.GetSharedWorkspaces.Workspaces) == 0 {
-		return WeWorkLocation{}, errors.New("no locations found")
+	return locations, nil
+}
+
+// Matches checks if the query is contained in the name, address or city of the location
+func (l WeWorkLocation) Matches(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	for _, field := range []string{l.Location.Name, l.Location.Address.Line1, l.Location.Address.City} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
 	}
 
-	return locationsResponse.GetSharedWorkspaces.Workspaces[0], nil
+	return false
 }
 
 func getBearerToken(ctx context.Context) (string, error) {
-	var token string
-
-	if err := chromedp.Run(ctx,
-		chromedp.EvaluateAsDevTools(`(function() {
-			const baseItems = localStorage.getItem('Auth0Config');
-			
-			if (!baseItems) {
-				throw new Error("could not find Auth0Config in local storage");
-			}
-			const config = JSON.parse(baseItems);
+	session, err := getAuth0Session(ctx)
 
-			const { clientId, authorizationParams: { scope } } = config;
+	if err != nil {
+		return "", err
+	}
 
-			const items = localStorage.getItem('@@auth0spajs@@::' + clientId + '::wework::openid ' + scope);
+	return session.AccessToken, nil
+}
 
-			if (!items) {
-				throw new Error("could not find auth0 items in local storage");
-			}
+// tokenExpiry reads the expiry from the "exp" claim of the JWT access token
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
 
-			return JSON.parse(items).body.access_token;
-		})()`, &token),
-	); err != nil {
-		return "", err
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("access token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
 	}
 
-	if token == "" {
-		return "", errors.New("could not find bearer token")
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, err
 	}
 
-	return token, nil
+	if claims.Exp == 0 {
+		return time.Time{}, errors.New("access token has no expiry")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
 }
 
 type BookingRequest struct {
@@ -222,66 +288,122 @@ type BookingResponse struct {
 	Errors        []string `json:"Errors"`
 	ReservationID string   `json:"ReservationID"`
 	WeworkUUID    string   `json:"WeWorkUUID"`
+	// Not returned by WeWork, it comes from the location or the existing reservation
+	CreditsUsed float64 `json:"-"`
 }
 
-func makeBookingRequest(ctx context.Context, token string, date time.Time, space WeWorkLocation) error {
-	request := resty.New().R()
+// The messages of a booking WeWork rejects because the space is full, compared as a
+// whole. Any other rejection is reported as is, and not waited on by the waitlist
+var noSeatsMessages = []string{"No seats available"}
 
-	request.SetAuthToken(token)
+// isNoSeatsError tells from the messages of a rejected booking whether the space is full
+func isNoSeatsError(messages []string) bool {
+	for _, message := range messages {
+		for _, noSeats := range noSeatsMessages {
+			if strings.EqualFold(strings.TrimSpace(message), noSeats) {
+				return true
+			}
+		}
+	}
 
-	request.SetContext(ctx)
+	return false
+}
+
+// Sent as is by the WeWork website with every booking, it does not change the price
+const weworkCreditRatio = 20
+
+// The SpaceType and LocationType of a shared desk. Only desks are booked, the codes of the
+// meeting rooms, private offices and day passes were never taken from real WeWork requests
+const (
+	deskSpaceType    = 4
+	deskLocationType = 2
+)
 
-	requestData := BookingRequest{
+// newBookingRequest books the space from start to end, see bookingSlot
+func newBookingRequest(start time.Time, end time.Time, space WeWorkLocation, credits float64) BookingRequest {
+	return BookingRequest{
 		ApplicationType:      "WorkplaceOne",
 		PlatformType:         "WEB",
-		SpaceType:            4,
+		SpaceType:            deskSpaceType,
 		ReservationID:        "",
 		TriggerCalendarEvent: false,
 		MailData: MailData{
-			DayFormatted:       GetEmailDateFormated(date),
-			StartTimeFormatted: space.OpenTime,
-			EndTimeFormatted:   space.CloseTime,
+			DayFormatted:       GetEmailDateFormated(start),
+			StartTimeFormatted: start.Format(time.Kitchen),
+			EndTimeFormatted:   end.Format(time.Kitchen),
 			LocationAddress:    space.Location.Address.Line1,
-			CreditsUsed:        "2",
+			CreditsUsed:        strconv.FormatFloat(credits, 'f', -1, 64),
 			Capacity:           "1",
-			TimezoneUsed:       "GMT +02:00",
+			TimezoneUsed:       formatUTCOffset(start),
 			TimezoneIana:       space.Location.TimeZoneIdentifier,
 			TimezoneWin:        space.Location.TimeZoneWinID,
-			StartDateTime:      fmt.Sprintf("%s 06:00", date.Format(time.DateOnly)),
-			EndDateTime:        fmt.Sprintf("%s 23:59", date.Format(time.DateOnly)),
+			StartDateTime:      start.Format("2006-01-02 15:04"),
+			EndDateTime:        end.Format("2006-01-02 15:04"),
 			LocationName:       space.Location.Name,
 			LocationCity:       space.Location.Address.City,
 			LocationCountry:    space.Location.Address.Country,
 			LocationState:      space.Location.Address.State,
 		},
-		LocationType:  2,
-		UTCOffset:     space.Location.TimezoneOffset,
-		CreditRatio:   20,
+		LocationType:  deskLocationType,
+		UTCOffset:     start.Format("-07:00"),
+		CreditRatio:   weworkCreditRatio,
 		LocationID:    space.Location.UUID,
 		SpaceID:       space.Reservable.KubeID,
 		WeWorkSpaceID: space.UUID,
-		StartTime:     fmt.Sprintf("%sT04:00:00Z", date.Format(time.DateOnly)),
-		EndTime:       fmt.Sprintf("%sT21:59:00Z", date.Format(time.DateOnly)),
+		StartTime:     start.UTC().Format(time.RFC3339),
+		EndTime:       end.UTC().Format(time.RFC3339),
 	}
+}
 
-	request.SetBody(requestData)
-
-	var bookingResponse BookingResponse
+type WeWorkBooking struct {
+	ReservationID string    `json:"reservationId"`
+	WeworkUUID    string    `json:"weWorkUuid"`
+	SpaceType     int       `json:"spaceType"`
+	LocationID    string    `json:"locationId"`
+	SpaceID       string    `json:"spaceId"`
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+	TimezoneIana  string    `json:"timezoneIana"`
+	CreditsUsed   float64   `json:"creditsUsed"`
+}
 
-	response, err := request.SetResult(&bookingResponse).
-		Post("https://members.wework.com/workplaceone/api/common-booking/")
+// Date returns the day of the booking in the timezone of the location
+func (b WeWorkBooking) Date() time.Time {
+	start := b.StartTime
 
-	if err != nil {
-		return err
+	if location, err := time.LoadLocation(b.TimezoneIana); err == nil {
+		start = start.In(location)
 	}
 
-	if response.IsError() {
-		return fmt.Errorf("error making booking request: %s", response.Status())
-	}
+	return time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+}
 
-	if bookingResponse.BookingStatus != "BookingSuccess" {
-		return fmt.Errorf("booking not confirmed: %v", bookingResponse.Errors)
-	}
+type WeWorkBookingsResponse struct {
+	Bookings []WeWorkBooking `json:"bookings"`
+}
 
-	return nil
+type CancelBookingRequest struct {
+	ApplicationType string `json:"ApplicationType"`
+	PlatformType    string `json:"PlatformType"`
+	SpaceType       int    `json:"SpaceType"`
+	ReservationID   string `json:"ReservationID"`
+	WeWorkUUID      string `json:"WeWorkUUID"`
+	LocationID      string `json:"LocationID"`
+}
+
+type CancelBookingResponse struct {
+	CancellationStatus string   `json:"CancellationStatus"`
+	Errors             []string `json:"Errors"`
+	CreditsRefunded    float64  `json:"CreditsRefunded"`
+}
+
+func newCancelBookingRequest(booking WeWorkBooking) CancelBookingRequest {
+	return CancelBookingRequest{
+		ApplicationType: "WorkplaceOne",
+		PlatformType:    "WEB",
+		SpaceType:       booking.SpaceType,
+		ReservationID:   booking.ReservationID,
+		WeWorkUUID:      booking.WeworkUUID,
+		LocationID:      booking.LocationID,
+	}
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWeWorkLocationMatches(t *testing.T) {
+	var location WeWorkLocation
+
+	location.Location.Name = "115 Broadway"
+	location.Location.Address.Line1 = "115 Broadway"
+	location.Location.Address.City = "New York"
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"115 broadway", true},
+		{" New York ", true},
+		{"york", true},
+		{"London", false},
+	}
+
+	for _, test := range tests {
+		if result := location.Matches(test.query); result != test.expected {
+			t.Errorf("For query %s, expected %v, but got %v", test.query, test.expected, result)
+		}
+	}
+}
+
+func TestIsNoSeatsError(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		expected bool
+	}{
+		{"no_seats", true},
+		{"space_not_found", false},
+		{"not_available_for_membership", false},
+		{"over_capacity", false},
+	}
+
+	for _, test := range tests {
+		data, err := os.ReadFile(filepath.Join("testdata", "booking", test.fixture+".json"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var response BookingResponse
+
+		if err := json.Unmarshal(data, &response); err != nil {
+			t.Fatal(err)
+		}
+
+		if isNoSeatsError(response.Errors) != test.expected {
+			t.Errorf("Expected %v for %s, but got %v", test.expected, test.fixture, !test.expected)
+		}
+	}
+}